@@ -0,0 +1,146 @@
+// coursectl imports and exports course bundles through the admin API.
+//
+//	coursectl import <dir | bundle.tar.gz>
+//	coursectl export <course_id> <dir | bundle.tar.gz>
+//
+// It authenticates with the admin JWT or API key in T_LEARN_TOKEN and talks
+// to T_LEARN_URL (http://localhost:8080 by default).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/bundle"
+)
+
+const defaultBaseURL = "http://localhost:8080"
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	token := os.Getenv("T_LEARN_TOKEN")
+	if token == "" {
+		log.Fatal("T_LEARN_TOKEN is not set")
+	}
+	baseURL := os.Getenv("T_LEARN_URL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	switch os.Args[1] {
+	case "import":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		importBundle(baseURL, token, os.Args[2])
+	case "export":
+		if len(os.Args) != 4 {
+			usage()
+		}
+		exportBundle(baseURL, token, os.Args[2], os.Args[3])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	log.Fatal("usage:\n  coursectl import <dir | bundle.tar.gz>\n  coursectl export <course_id> <dir | bundle.tar.gz>")
+}
+
+func isArchive(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+func importBundle(baseURL, token, source string) {
+	var archive []byte
+
+	if isArchive(source) {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			log.Fatal(err)
+		}
+		archive = data
+	} else {
+		// Parse the directory locally first so mistakes are reported
+		// before anything is uploaded
+		course, err := bundle.ReadDir(source)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := bundle.WriteArchive(&buf, course); err != nil {
+			log.Fatal(err)
+		}
+		archive = buf.Bytes()
+	}
+
+	resp := doRequest("POST", baseURL+"/admin/courses/import", token, "application/gzip", archive)
+	defer resp.Body.Close()
+
+	var created struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	fmt.Printf("Imported %q as course %s\n", created.Title, created.ID)
+}
+
+func exportBundle(baseURL, token, courseID, target string) {
+	resp := doRequest("GET", baseURL+"/admin/courses/"+courseID+"/export", token, "", nil)
+	defer resp.Body.Close()
+
+	if isArchive(target) {
+		f, err := os.Create(target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := io.Copy(f, resp.Body); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		course, err := bundle.ReadArchive(resp.Body)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := bundle.WriteDir(target, course); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("Exported course %s to %s\n", courseID, target)
+}
+
+func doRequest(method, url, token, contentType string, body []byte) *http.Response {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal("Request failed (is the server running?): ", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Fatalf("API Error [%s] %s: %d %s", method, url, resp.StatusCode, string(respBody))
+	}
+	return resp
+}
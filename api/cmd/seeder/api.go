@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// --- Remote State ---
+
+type remoteCourse struct {
+	ID          string         `json:"id"`
+	Slug        string         `json:"slug"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Lessons     []remoteLesson `json:"lessons"`
+}
+
+type remoteLesson struct {
+	ID       string       `json:"id"`
+	Slug     string       `json:"slug"`
+	Title    string       `json:"title"`
+	Content  string       `json:"content"`
+	Position int          `json:"position"`
+	Tasks    []remoteTask `json:"tasks"`
+}
+
+type remoteTask struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description"`
+	Steps       []remoteStep `json:"steps"`
+}
+
+type remoteStep struct {
+	ID             string `json:"id"`
+	Position       int    `json:"position"`
+	Command        string `json:"command"`
+	ExpectedOutput string `json:"expected_output"`
+	MatchMode      string `json:"match_mode"`
+}
+
+// stepPayload is the JSON body for creating or replacing a task step.
+type stepPayload struct {
+	Command        string `json:"command"`
+	ExpectedOutput string `json:"expected_output"`
+	MatchMode      string `json:"match_mode,omitempty"`
+	Position       int    `json:"position"`
+}
+
+func seedSteps(task TaskSeed) []stepPayload {
+	return []stepPayload{
+		{
+			Position:       1,
+			Command:        task.Command,
+			ExpectedOutput: task.ExpectedOutput,
+			MatchMode:      task.MatchMode,
+		},
+	}
+}
+
+// --- Helpers ---
+
+// loginAndGetToken returns an access token and the refresh token of its
+// session, which logout ends.
+func loginAndGetToken() (string, string) {
+	payload := map[string]string{
+		"username": AdminUser,
+		"password": AdminPass,
+	}
+	body, _ := json.Marshal(payload)
+
+	var res struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		MFARequired  bool   `json:"mfa_required"`
+		MFAToken     string `json:"mfa_token"`
+	}
+	postJSON("/auth/login", body, &res)
+
+	// Admins with 2FA enabled need a code from their authenticator app
+	if res.MFARequired {
+		fmt.Print("Two-factor code for admin: ")
+		code, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+		body, _ := json.Marshal(map[string]string{
+			"mfa_token": res.MFAToken,
+			"code":      strings.TrimSpace(code),
+		})
+		postJSON("/auth/login/2fa", body, &res)
+	}
+
+	return res.Token, res.RefreshToken
+}
+
+// logout ends the session of the seeder, so runs don't pile up sessions of
+// the admin. A failure only warns, the seeding itself is done by then.
+func logout(refreshToken string) {
+	body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	resp, err := http.Post(BaseURL+"/auth/logout", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		log.Println("Logout failed:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 204 {
+		b, _ := io.ReadAll(resp.Body)
+		log.Printf("Logout failed: %d %s", resp.StatusCode, string(b))
+	}
+}
+
+func postJSON(path string, body []byte, target interface{}) {
+	resp, err := http.Post(BaseURL+path, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		log.Fatal("Login failed (is server running?):", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		log.Fatalf("Login failed: %d %s", resp.StatusCode, string(b))
+	}
+
+	json.NewDecoder(resp.Body).Decode(target)
+}
+
+func listCourses(token string) []remoteCourse {
+	var res []remoteCourse
+	doRequest("GET", "/admin/courses", token, nil, &res) // Drafts included
+	return res
+}
+
+func getCourseDetail(token, courseID string) remoteCourse {
+	var res remoteCourse
+	doRequest("GET", "/admin/courses/"+courseID, token, nil, &res)
+	return res
+}
+
+func createCourse(token, slug, title, desc string) string {
+	payload := map[string]string{
+		"slug":        slug,
+		"title":       title,
+		"description": desc,
+		"status":      "published", // The curriculum is ready to use
+	}
+	data, _ := json.Marshal(payload)
+
+	var res struct {
+		ID string `json:"id"`
+	}
+	doRequest("POST", "/admin/courses", token, data, &res)
+	return res.ID
+}
+
+// createLesson adds a lesson at the end of the course.
+func createLesson(token, courseID, slug, title, content string) string {
+	payload := map[string]interface{}{
+		"slug":    slug,
+		"title":   title,
+		"content": content,
+	}
+	data, _ := json.Marshal(payload)
+
+	var res struct {
+		ID string `json:"id"`
+	}
+	path := fmt.Sprintf("/admin/courses/%s/lessons", courseID)
+	doRequest("POST", path, token, data, &res)
+	return res.ID
+}
+
+// reorderLessons moves the lessons of a course into the given order in one
+// request, lessonIDs has to list every lesson of the course.
+func reorderLessons(token, courseID string, lessonIDs []string) {
+	data, _ := json.Marshal(map[string]interface{}{"lesson_ids": lessonIDs})
+	doRequest("PUT", fmt.Sprintf("/admin/courses/%s/lessons/order", courseID), token, data, nil)
+}
+
+func createTask(token, lessonID string, task TaskSeed) {
+	payload := map[string]interface{}{
+		"description": task.Description,
+		"steps":       seedSteps(task),
+	}
+	data, _ := json.Marshal(payload)
+
+	path := fmt.Sprintf("/admin/lessons/%s/tasks", lessonID)
+	doRequest("POST", path, token, data, nil)
+}
+
+func createStep(token, taskID string, step stepPayload) {
+	data, _ := json.Marshal(step)
+	doRequest("POST", fmt.Sprintf("/admin/tasks/%s/steps", taskID), token, data, nil)
+}
+
+// patch sends a partial update to an admin resource, e.g. patch(token, "lessons", id, ...).
+func patch(token, resource, id string, fields map[string]interface{}) {
+	data, _ := json.Marshal(fields)
+	doRequest("PATCH", fmt.Sprintf("/admin/%s/%s", resource, id), token, data, nil)
+}
+
+func remove(token, resource, id string) {
+	doRequest("DELETE", fmt.Sprintf("/admin/%s/%s", resource, id), token, nil, nil)
+}
+
+func doRequest(method, path, token string, body []byte, target interface{}) {
+	req, _ := http.NewRequest(method, BaseURL+path, bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Fatalf("API Error [%s] %s: %s", method, path, string(respBody))
+	}
+
+	if target != nil {
+		json.NewDecoder(resp.Body).Decode(target)
+	}
+}
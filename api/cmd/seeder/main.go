@@ -1,14 +1,11 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 
 	"github.com/Tikkaaa3/t-learn/api/internal/auth"
@@ -51,33 +48,47 @@ type TaskSeed struct {
 	Description    string
 	Command        string
 	ExpectedOutput string
+	MatchMode      string // Optional, the server defaults to "trimmed"
 }
 
+// Slugs identify courses and lessons across runs. Changing a title updates
+// the existing row, changing a slug replaces it.
+
 type LessonSeed struct {
+	Slug     string
 	Title    string
 	Content  string
 	TaskSeed TaskSeed
 }
 
 type CourseSeed struct {
+	Slug        string
 	Title       string
 	Description string
 	Lessons     []LessonSeed
 }
 
 func main() {
+	dryRun := flag.Bool("dry-run", false, "print the plan without changing anything")
+	prune := flag.Bool("prune", false, "also delete courses that are not part of the curriculum")
+	flag.Parse()
+
 	// Setup Env & DB Connection
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, relying on system env")
 	}
 
-	// Create Admin User directly in DB
-	bootstrapAdmin()
+	// Create Admin User directly in DB (a dry run must not write anything)
+	if !*dryRun {
+		bootstrapAdmin()
+	}
 
 	fmt.Println("Starting API-Based Seeder...")
 
-	// Login to get the Token
-	token := loginAndGetToken()
+	// Login to get the Token. Reading the courses needs an admin even for a
+	// dry run, the session is ended again on the way out.
+	token, refreshToken := loginAndGetToken()
+	defer logout(refreshToken)
 	fmt.Println("Logged in as Admin")
 
 	// Define Curriculum
@@ -86,10 +97,12 @@ func main() {
 		// PYTHON BASICS
 		// ==========================================
 		{
+			Slug:        "python-basics",
 			Title:       "Python Basics",
 			Description: "Start your journey with Python 3. Learn syntax, variables, and loops.",
 			Lessons: []LessonSeed{
 				{
+					Slug:  "hello-python",
 					Title: "Hello Python",
 					Content: `
 ### The Print Function
@@ -115,6 +128,7 @@ print('Python is fun')
 					},
 				},
 				{
+					Slug:  "variables-math",
 					Title: "Variables & Math",
 					Content: `
 ### Variables
@@ -142,6 +156,7 @@ Python supports standard math operators:
 					},
 				},
 				{
+					Slug:  "for-loops",
 					Title: "For Loops",
 					Content: `
 ### The For Loop
@@ -170,10 +185,12 @@ for i in range(5):
 		// GO BASICS
 		// ==========================================
 		{
+			Slug:        "go-basics",
 			Title:       "Go Basics",
 			Description: "Master the fundamentals of Golang: Static typing, packages, and compilation.",
 			Lessons: []LessonSeed{
 				{
+					Slug:  "hello-go",
 					Title: "Hello Go",
 					Content: `
 ### Structure of a Go Program
@@ -200,6 +217,7 @@ func main() {
 					},
 				},
 				{
+					Slug:  "integers-variables",
 					Title: "Integers & Variables",
 					Content: `
 ### Declaring Variables
@@ -223,6 +241,7 @@ y := 20 // Go knows this is an int
 					},
 				},
 				{
+					Slug:  "functions",
 					Title: "Functions",
 					Content: `
 ### Functions
@@ -250,10 +269,12 @@ You must call the function from ` + "`main`" + ` for it to run.
 		// RUST BASICS
 		// ==========================================
 		{
+			Slug:        "rust-basics",
 			Title:       "Rust Basics",
 			Description: "Learn memory safety and modern systems programming with Rust.",
 			Lessons: []LessonSeed{
 				{
+					Slug:  "hello-rust",
 					Title: "Hello Rust",
 					Content: `
 ### Macros vs Functions
@@ -276,6 +297,7 @@ fn main() {
 					},
 				},
 				{
+					Slug:  "variables-let",
 					Title: "Variables (Let)",
 					Content: `
 ### Variable Binding
@@ -297,6 +319,7 @@ fn main() {
 					},
 				},
 				{
+					Slug:  "mutability",
 					Title: "Mutability",
 					Content: `
 ### Immutable by Default
@@ -320,19 +343,25 @@ x = 6; // This is allowed because of 'mut'
 		},
 	}
 
-	// Loop and Seed
-	for _, course := range curriculum {
-		fmt.Printf("\n--- Seeding Course: %s ---\n", course.Title)
-		courseID := createCourse(token, course.Title, course.Description)
+	// Compare the curriculum with what the API already has
+	plan := buildPlan(token, curriculum, *prune)
+	if len(plan) == 0 {
+		fmt.Println("\nEverything is up to date.")
+		return
+	}
 
-		for i, lesson := range course.Lessons {
-			// AUTOMATICALLY APPEND HELPER TEXT
-			fullContent := lesson.Content + cliHelper
+	fmt.Printf("\nPlan (%d changes):\n", len(plan))
+	for _, a := range plan {
+		fmt.Println("  " + a.String())
+	}
 
-			// Position is i+1
-			lessonID := createLesson(token, courseID, lesson.Title, fullContent, i+1)
-			createTask(token, lessonID, lesson.TaskSeed)
-		}
+	if *dryRun {
+		fmt.Println("\nDry run, nothing was changed.")
+		return
+	}
+
+	for _, a := range plan {
+		a.apply()
 	}
 
 	fmt.Println("\nSeeding Complete! Python, Go, and Rust courses are ready.")
@@ -355,10 +384,10 @@ func bootstrapAdmin() {
 	queries := database.New(conn)
 	ctx := context.Background()
 
-	_, err = queries.GetUserByUsername(ctx, AdminUser)
+	user, err := queries.GetUserByUsername(ctx, AdminUser)
 	if err != nil {
 		hashedPassword, _ := auth.HashPassword(AdminPass)
-		_, err := queries.CreateUser(ctx, database.CreateUserParams{
+		user, err = queries.CreateUser(ctx, database.CreateUserParams{
 			Username:     AdminUser,
 			Email:        "admin@t-learn.com",
 			PasswordHash: hashedPassword,
@@ -369,108 +398,13 @@ func bootstrapAdmin() {
 		fmt.Println("Created 'admin' user.")
 	}
 
-	_, err = conn.Exec("UPDATE users SET role = 'admin' WHERE username = $1", AdminUser)
+	// The very first admin can't be promoted through the API, every later
+	// one can be with PUT /admin/users/{user_id}/role
+	_, err = queries.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: "admin",
+	})
 	if err != nil {
 		log.Fatal("Failed to promote user to admin:", err)
 	}
 }
-
-func loginAndGetToken() string {
-	payload := map[string]string{
-		"username": AdminUser,
-		"password": AdminPass,
-	}
-	body, _ := json.Marshal(payload)
-
-	resp, err := http.Post(BaseURL+"/auth/login", "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		log.Fatal("Login failed (is server running?):", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		log.Fatalf("Login failed: %s", string(b))
-	}
-
-	var res struct {
-		Token string `json:"token"`
-	}
-	json.NewDecoder(resp.Body).Decode(&res)
-	return res.Token
-}
-
-func createCourse(token, title, desc string) string {
-	payload := map[string]string{
-		"title":       title,
-		"description": desc,
-	}
-	data, _ := json.Marshal(payload)
-
-	var res struct {
-		ID string `json:"id"`
-	}
-	doRequest("POST", "/admin/courses", token, data, &res)
-	return res.ID
-}
-
-func createLesson(token, courseID, title, content string, position int) string {
-	payload := map[string]interface{}{
-		"title":    title,
-		"content":  content,
-		"position": position,
-	}
-	data, _ := json.Marshal(payload)
-
-	var res struct {
-		ID string `json:"id"`
-	}
-	path := fmt.Sprintf("/admin/courses/%s/lessons", courseID)
-	doRequest("POST", path, token, data, &res)
-	return res.ID
-}
-
-func createTask(token, lessonID string, task TaskSeed) {
-	type Step struct {
-		Command        string `json:"command"`
-		ExpectedOutput string `json:"expected_output"`
-		Position       int    `json:"position"`
-	}
-
-	payload := map[string]interface{}{
-		"description": task.Description,
-		"steps": []Step{
-			{
-				Position:       1,
-				Command:        task.Command,
-				ExpectedOutput: task.ExpectedOutput,
-			},
-		},
-	}
-	data, _ := json.Marshal(payload)
-
-	path := fmt.Sprintf("/admin/lessons/%s/task", lessonID)
-	doRequest("POST", path, token, data, nil)
-}
-
-func doRequest(method, path, token string, body []byte, target interface{}) {
-	req, _ := http.NewRequest(method, BaseURL+path, bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		respBody, _ := io.ReadAll(resp.Body)
-		log.Fatalf("API Error [%s] %s: %s", method, path, string(respBody))
-	}
-
-	if target != nil {
-		json.NewDecoder(resp.Body).Decode(target)
-	}
-}
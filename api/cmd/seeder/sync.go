@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// action is a single change the seeder wants to make through the API.
+type action struct {
+	kind        byte // '+' create, '~' update, '-' delete
+	description string
+	apply       func()
+}
+
+func (a action) String() string {
+	return fmt.Sprintf("%c %s", a.kind, a.description)
+}
+
+// buildPlan compares the curriculum with what the API already has, matching
+// courses and lessons by slug, and returns the changes needed to make them
+// equal. Running the seeder again right after applying the plan yields an
+// empty plan. Courses that are not in the curriculum are only deleted when
+// prune is set.
+func buildPlan(token string, curriculum []CourseSeed, prune bool) []action {
+	remoteBySlug := make(map[string]remoteCourse)
+	for _, c := range listCourses(token) {
+		remoteBySlug[c.Slug] = c
+	}
+
+	var plan []action
+	wanted := make(map[string]bool, len(curriculum))
+
+	for _, course := range curriculum {
+		wanted[course.Slug] = true
+
+		remote, exists := remoteBySlug[course.Slug]
+		if !exists {
+			plan = append(plan, createCourseAction(token, course))
+			continue
+		}
+
+		plan = append(plan, planCourse(token, course, getCourseDetail(token, remote.ID))...)
+	}
+
+	if prune {
+		for slug, remote := range remoteBySlug {
+			if wanted[slug] {
+				continue
+			}
+			plan = append(plan, action{'-', fmt.Sprintf("delete course %q", remote.Title), func() {
+				remove(token, "courses", remote.ID)
+			}})
+		}
+	}
+
+	return plan
+}
+
+func createCourseAction(token string, course CourseSeed) action {
+	return action{'+', fmt.Sprintf("create course %q with %d lessons", course.Title, len(course.Lessons)), func() {
+		courseID := createCourse(token, course.Slug, course.Title, course.Description)
+		for _, lesson := range course.Lessons {
+			// Each lesson goes at the end, so they keep the curriculum order
+			lessonID := createLesson(token, courseID, lesson.Slug, lesson.Title, lesson.Content+cliHelper)
+			createTask(token, lessonID, lesson.TaskSeed)
+		}
+	}}
+}
+
+// planCourse diffs one existing course. Deletes come first so the lessons
+// that are left can be reordered, and new lessons are created at the end.
+// Steps are the exception, see planTask.
+// The order is fixed last with a single reorder request, moving lessons one
+// at a time would collide with the position of another lesson.
+func planCourse(token string, course CourseSeed, remote remoteCourse) []action {
+	var deletes, updates, creates []action
+
+	if remote.Title != course.Title || remote.Description != course.Description {
+		updates = append(updates, action{'~', fmt.Sprintf("update course %q", course.Title), func() {
+			patch(token, "courses", remote.ID, map[string]interface{}{
+				"title":       course.Title,
+				"description": course.Description,
+			})
+		}})
+	}
+
+	remoteLessons := make(map[string]remoteLesson, len(remote.Lessons))
+	for _, l := range remote.Lessons {
+		remoteLessons[l.Slug] = l
+	}
+
+	// Filled in while the plan is applied, as new lessons get their IDs
+	lessonIDs := make(map[string]string, len(course.Lessons))
+
+	wanted := make(map[string]bool, len(course.Lessons))
+	var added []string
+	for _, lesson := range course.Lessons {
+		wanted[lesson.Slug] = true
+		content := lesson.Content + cliHelper
+
+		existing, ok := remoteLessons[lesson.Slug]
+		if !ok {
+			added = append(added, lesson.Slug)
+			creates = append(creates, action{'+', fmt.Sprintf("create lesson %q in %q", lesson.Title, course.Title), func() {
+				lessonID := createLesson(token, remote.ID, lesson.Slug, lesson.Title, content)
+				lessonIDs[lesson.Slug] = lessonID
+				createTask(token, lessonID, lesson.TaskSeed)
+			}})
+			continue
+		}
+		lessonIDs[lesson.Slug] = existing.ID
+
+		if existing.Title != lesson.Title || existing.Content != content {
+			updates = append(updates, action{'~', fmt.Sprintf("update lesson %q in %q", lesson.Title, course.Title), func() {
+				patch(token, "lessons", existing.ID, map[string]interface{}{
+					"title":   lesson.Title,
+					"content": content,
+				})
+			}})
+		}
+
+		lessonDeletes, lessonUpdates, lessonCreates := planTask(token, lesson, existing)
+		deletes = append(deletes, lessonDeletes...)
+		updates = append(updates, lessonUpdates...)
+		creates = append(creates, lessonCreates...)
+	}
+
+	kept := make([]remoteLesson, 0, len(remote.Lessons))
+	for _, l := range remote.Lessons {
+		if wanted[l.Slug] {
+			kept = append(kept, l)
+			continue
+		}
+		deletes = append(deletes, action{'-', fmt.Sprintf("delete lesson %q from %q", l.Title, course.Title), func() {
+			remove(token, "lessons", l.ID)
+		}})
+	}
+
+	plan := append(append(deletes, updates...), creates...)
+
+	// The order the course ends up in without a reorder: the lessons that
+	// are kept, then the new ones in curriculum order
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Position < kept[j].Position })
+	order := make([]string, 0, len(course.Lessons))
+	for _, l := range kept {
+		order = append(order, l.Slug)
+	}
+	order = append(order, added...)
+
+	for i, lesson := range course.Lessons {
+		if order[i] == lesson.Slug {
+			continue
+		}
+		plan = append(plan, action{'~', fmt.Sprintf("reorder lessons of %q", course.Title), func() {
+			ids := make([]string, len(course.Lessons))
+			for i, lesson := range course.Lessons {
+				ids[i] = lessonIDs[lesson.Slug]
+			}
+			reorderLessons(token, remote.ID, ids)
+		}})
+		break
+	}
+
+	return plan
+}
+
+// planTask diffs the task of an existing lesson, matching steps by position.
+// Seeded lessons have a single task, any tasks after the first are deleted.
+// Unwanted steps are deleted after the new ones are created, the server
+// refuses to delete the last step of a task.
+func planTask(token string, lesson LessonSeed, remote remoteLesson) (deletes, updates, creates []action) {
+	if len(remote.Tasks) == 0 {
+		creates = append(creates, action{'+', fmt.Sprintf("create task for %q", lesson.Title), func() {
+			createTask(token, remote.ID, lesson.TaskSeed)
+		}})
+		return
+	}
+
+	for _, extra := range remote.Tasks[1:] {
+		deletes = append(deletes, action{'-', fmt.Sprintf("delete extra task of %q", lesson.Title), func() {
+			remove(token, "tasks", extra.ID)
+		}})
+	}
+
+	task := remote.Tasks[0]
+	if task.Description != lesson.TaskSeed.Description {
+		updates = append(updates, action{'~', fmt.Sprintf("update task of %q", lesson.Title), func() {
+			patch(token, "tasks", task.ID, map[string]interface{}{
+				"description": lesson.TaskSeed.Description,
+			})
+		}})
+	}
+
+	remoteSteps := make(map[int]remoteStep, len(task.Steps))
+	for _, s := range task.Steps {
+		remoteSteps[s.Position] = s
+	}
+
+	wanted := make(map[int]bool)
+	for _, step := range seedSteps(lesson.TaskSeed) {
+		wanted[step.Position] = true
+
+		existing, ok := remoteSteps[step.Position]
+		if !ok {
+			creates = append(creates, action{'+', fmt.Sprintf("create step %d of %q", step.Position, lesson.Title), func() {
+				createStep(token, task.ID, step)
+			}})
+			continue
+		}
+
+		mode := step.MatchMode
+		if mode == "" {
+			mode = "trimmed" // The server's default
+		}
+		if existing.Command != step.Command || existing.ExpectedOutput != step.ExpectedOutput || existing.MatchMode != mode {
+			updates = append(updates, action{'~', fmt.Sprintf("update step %d of %q", step.Position, lesson.Title), func() {
+				patch(token, "steps", existing.ID, map[string]interface{}{
+					"command":         step.Command,
+					"expected_output": step.ExpectedOutput,
+					"match_mode":      mode,
+				})
+			}})
+		}
+	}
+
+	for _, s := range task.Steps {
+		if wanted[s.Position] {
+			continue
+		}
+		creates = append(creates, action{'-', fmt.Sprintf("delete step %d of %q", s.Position, lesson.Title), func() {
+			remove(token, "steps", s.ID)
+		}})
+	}
+
+	return
+}
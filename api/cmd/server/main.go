@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/Tikkaaa3/t-learn/api/internal/auth"
 	"github.com/Tikkaaa3/t-learn/api/internal/content"
 	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/mailer"
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc"
+	"github.com/Tikkaaa3/t-learn/api/internal/runner"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
 )
@@ -27,12 +33,65 @@ func main() {
 
 	dbQueries := database.New(dbConn)
 
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
+
+	// There is no fallback key, tokens signed with a default could be forged
+	// by anyone who has read the source
+	tokens, err := auth.TokensFromEnv(publicURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	providers, err := oidc.FromEnv(context.Background(), publicURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	authHandler := &auth.Handler{
-		DB: dbQueries,
+		DB:              dbQueries,
+		Conn:            dbConn,
+		FrontendURL:     frontendURL,
+		Mailer:          mail,
+		Tokens:          tokens,
+		Providers:       providers,
+		TrustProxy:      os.Getenv("TRUST_PROXY") == "true",
+		RequireAdmin2FA: os.Getenv("REQUIRE_ADMIN_2FA") == "true",
+	}
+
+	// Deleted content can be restored from the trash until it is purged
+	trashRetention, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || trashRetention < 1 {
+		trashRetention = content.DefaultTrashRetention
 	}
 
 	contentHandler := &content.Handler{
-		DB: dbQueries,
+		DB:             dbQueries,
+		Conn:           dbConn,
+		TrashRetention: int32(trashRetention),
+	}
+	go contentHandler.RunTrashPurge(context.Background(), time.Hour)
+
+	// Server-side grading is opt-in, it needs the course toolchains installed
+	if os.Getenv("RUNNER_ENABLED") == "true" {
+		maxConcurrent, err := strconv.Atoi(os.Getenv("RUNNER_MAX_CONCURRENT"))
+		if err != nil {
+			maxConcurrent = 4
+		}
+		contentHandler.Runner = runner.New(runner.DefaultLimits, maxConcurrent)
+		log.Printf("Server-side runner enabled (%d concurrent runs)", maxConcurrent)
 	}
 
 	mux := http.NewServeMux()
@@ -41,28 +100,130 @@ func main() {
 		//
 	})
 
+	mux.HandleFunc("GET /.well-known/jwks.json", authHandler.JWKS)
+
 	// Auth Routes
 	mux.HandleFunc("POST /auth/register", authHandler.Register)
 	mux.HandleFunc("POST /auth/login", authHandler.Login)
+	mux.HandleFunc("POST /auth/login/2fa", authHandler.LoginMFA)
+	mux.HandleFunc("POST /auth/refresh", authHandler.Refresh)
+	mux.HandleFunc("POST /auth/logout", authHandler.Logout)
 	mux.HandleFunc("GET /auth/me", authHandler.MiddlewareAuth(func(w http.ResponseWriter, r *http.Request, user database.User) {
 		w.Write([]byte("Hello, " + user.Username))
 	}))
-	mux.HandleFunc("POST /auth/token", authHandler.MiddlewareAuth(authHandler.GenerateAPIKey))
+	mux.HandleFunc("POST /auth/token", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.GenerateAPIKey)))
+	mux.HandleFunc("POST /auth/verify-email", authHandler.VerifyEmail)
+	mux.HandleFunc("POST /auth/verify-email/request", authHandler.MiddlewareAuth(authHandler.RequestEmailVerification))
+	mux.HandleFunc("POST /auth/password-reset", authHandler.ResetPassword)
+	mux.HandleFunc("POST /auth/password-reset/request", authHandler.RequestPasswordReset)
+	mux.HandleFunc("POST /auth/device/code", authHandler.RequestDeviceCode)
+	mux.HandleFunc("POST /auth/device/token", authHandler.PollDeviceToken)
+	mux.HandleFunc("GET /auth/device/{user_code}", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.GetDeviceCode)))
+	mux.HandleFunc("POST /auth/device/{user_code}/approve", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.ApproveDeviceCode)))
+	mux.HandleFunc("POST /auth/device/{user_code}/deny", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.DenyDeviceCode)))
+	mux.HandleFunc("GET /auth/2fa", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.Get2FAStatus)))
+	mux.HandleFunc("POST /auth/2fa/setup", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.Setup2FA)))
+	mux.HandleFunc("POST /auth/2fa/enable", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.Enable2FA)))
+	mux.HandleFunc("POST /auth/2fa/disable", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.Disable2FA)))
+	mux.HandleFunc("POST /auth/2fa/recovery-codes", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.RegenerateRecoveryCodes)))
+	mux.HandleFunc("GET /auth/sso", authHandler.ListSSOProviders)
+	mux.HandleFunc("GET /auth/sso/{provider}/login", authHandler.StartSSOLogin)
+	mux.HandleFunc("GET /auth/sso/{provider}/callback", authHandler.SSOCallback)
+	mux.HandleFunc("POST /auth/sso/{provider}/link", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.LinkSSO)))
+	mux.HandleFunc("POST /auth/sso/{provider}/reauth", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.ReauthSSO)))
+	mux.HandleFunc("POST /auth/sso/token", authHandler.ExchangeSSOCode)
+	mux.HandleFunc("GET /auth/keys", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.ListAPIKeys)))
+	mux.HandleFunc("POST /auth/keys", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.CreateAPIKey)))
+	mux.HandleFunc("DELETE /auth/keys/{key_id}", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.RevokeAPIKey)))
+
+	// Account Routes
+	mux.HandleFunc("GET /me", authHandler.MiddlewareAuth(authHandler.GetMe))
+	mux.HandleFunc("PATCH /me", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.UpdateMe)))
+	mux.HandleFunc("POST /me/password", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.ChangePassword)))
+	mux.HandleFunc("GET /me/export", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.ExportMe)))
+	mux.HandleFunc("GET /me/identities", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.ListIdentities)))
+	mux.HandleFunc("DELETE /me/identities/{identity_id}", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.UnlinkIdentity)))
+	mux.HandleFunc("DELETE /me", authHandler.MiddlewareAuth(auth.RequireSession(authHandler.DeleteMe)))
 
 	// Content Routes
 	mux.HandleFunc("GET /courses", contentHandler.GetCourses)
-	mux.HandleFunc("GET /courses/{course_id}/lessons", authHandler.MiddlewareAuth(contentHandler.GetLessons))
+	mux.HandleFunc("GET /courses/{course_id}/lessons", authHandler.MiddlewareAuth(auth.RequireScope(auth.ScopeReadContent, contentHandler.GetLessons)))
 	mux.HandleFunc("GET /lessons/{lesson_id}/task", contentHandler.GetTask)
-	mux.HandleFunc("POST /tasks/{task_id}/complete", authHandler.MiddlewareAuth(contentHandler.CompleteTask))
+	mux.HandleFunc("POST /lessons/{lesson_id}/read", authHandler.MiddlewareAuth(auth.RequireScope(auth.ScopeSubmit, contentHandler.MarkLessonRead)))
+	mux.HandleFunc("POST /tasks/{task_id}/submit", authHandler.MiddlewareAuth(auth.RequireScope(auth.ScopeSubmit, contentHandler.SubmitTask)))
+	mux.HandleFunc("POST /tasks/{task_id}/run", authHandler.MiddlewareAuth(auth.RequireScope(auth.ScopeSubmit, contentHandler.RunTask)))
+	mux.HandleFunc("GET /tasks/{task_id}/submissions", authHandler.MiddlewareAuth(auth.RequireScope(auth.ScopeReadContent, contentHandler.GetMySubmissions)))
+
+	// Admin Routes, each checks a permission on the course the request is about
+	perm := authHandler.MiddlewarePermission
+	mux.HandleFunc("GET /admin/courses", perm(auth.PermCourseView, nil, contentHandler.ListCourses))
+	mux.HandleFunc("POST /admin/courses", perm(auth.PermCourseCreate, nil, contentHandler.CreateCourse))
+	mux.HandleFunc("GET /admin/courses/{course_id}", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.GetCourseDetail))
+	mux.HandleFunc("POST /admin/courses/import", perm(auth.PermCourseCreate, nil, contentHandler.ImportCourse))
+	mux.HandleFunc("GET /admin/courses/{course_id}/export", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.ExportCourse))
+	mux.HandleFunc("POST /admin/courses/{course_id}/lessons", perm(auth.PermContentEdit, auth.CourseFromPath, contentHandler.CreateLesson))
+	mux.HandleFunc("POST /admin/lessons/{lesson_id}/tasks", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.CreateTask))
+	mux.HandleFunc("POST /admin/lessons/{lesson_id}/task", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.CreateTask)) // Before lessons had several tasks
+	mux.HandleFunc("POST /admin/tasks/{task_id}/steps", perm(auth.PermContentEdit, authHandler.CourseFromTask, contentHandler.CreateTaskStep))
+	mux.HandleFunc("PUT /admin/courses/{course_id}/lessons/order", perm(auth.PermContentEdit, auth.CourseFromPath, contentHandler.ReorderLessons))
+	mux.HandleFunc("PUT /admin/lessons/{lesson_id}/tasks/order", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.ReorderTasks))
+	mux.HandleFunc("PUT /admin/tasks/{task_id}/steps/order", perm(auth.PermContentEdit, authHandler.CourseFromTask, contentHandler.ReorderTaskSteps))
+	mux.HandleFunc("PUT /admin/courses/{course_id}/status", perm(auth.PermContentEdit, auth.CourseFromPath, contentHandler.SetCourseStatus))
+	mux.HandleFunc("PUT /admin/lessons/{lesson_id}/status", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.SetLessonStatus))
+	mux.HandleFunc("GET /admin/preview/courses/{course_id}/lessons", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.PreviewLessons))
+	mux.HandleFunc("GET /admin/preview/lessons/{lesson_id}/task", perm(auth.PermCourseView, authHandler.CourseFromLesson, contentHandler.PreviewTask))
+	mux.HandleFunc("GET /admin/lessons/{lesson_id}/submissions", perm(auth.PermSubmissionsView, authHandler.CourseFromLesson, contentHandler.GetLessonSubmissions))
+
+	mux.HandleFunc("PUT /admin/courses/{course_id}", perm(auth.PermContentEdit, auth.CourseFromPath, contentHandler.UpdateCourse))
+	mux.HandleFunc("PATCH /admin/courses/{course_id}", perm(auth.PermContentEdit, auth.CourseFromPath, contentHandler.UpdateCourse))
+	mux.HandleFunc("PUT /admin/lessons/{lesson_id}", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.UpdateLesson))
+	mux.HandleFunc("PATCH /admin/lessons/{lesson_id}", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.UpdateLesson))
+	mux.HandleFunc("PUT /admin/tasks/{task_id}", perm(auth.PermContentEdit, authHandler.CourseFromTask, contentHandler.UpdateTask))
+	mux.HandleFunc("PATCH /admin/tasks/{task_id}", perm(auth.PermContentEdit, authHandler.CourseFromTask, contentHandler.UpdateTask))
+	mux.HandleFunc("PUT /admin/steps/{step_id}", perm(auth.PermContentEdit, authHandler.CourseFromStep, contentHandler.UpdateTaskStep))
+	mux.HandleFunc("PATCH /admin/steps/{step_id}", perm(auth.PermContentEdit, authHandler.CourseFromStep, contentHandler.UpdateTaskStep))
+
+	mux.HandleFunc("GET /admin/courses/{course_id}/revisions", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.ListRevisions))
+	mux.HandleFunc("GET /admin/courses/{course_id}/revisions/diff", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.DiffRevisions))
+	mux.HandleFunc("GET /admin/courses/{course_id}/revisions/{version}", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.GetRevision))
+	mux.HandleFunc("POST /admin/courses/{course_id}/revisions/{version}/rollback", perm(auth.PermContentEdit, auth.CourseFromPath, contentHandler.RollbackRevision))
+	mux.HandleFunc("GET /admin/lessons/{lesson_id}/revisions", perm(auth.PermCourseView, authHandler.CourseFromLesson, contentHandler.ListRevisions))
+	mux.HandleFunc("GET /admin/lessons/{lesson_id}/revisions/diff", perm(auth.PermCourseView, authHandler.CourseFromLesson, contentHandler.DiffRevisions))
+	mux.HandleFunc("GET /admin/lessons/{lesson_id}/revisions/{version}", perm(auth.PermCourseView, authHandler.CourseFromLesson, contentHandler.GetRevision))
+	mux.HandleFunc("POST /admin/lessons/{lesson_id}/revisions/{version}/rollback", perm(auth.PermContentEdit, authHandler.CourseFromLesson, contentHandler.RollbackRevision))
+	mux.HandleFunc("GET /admin/tasks/{task_id}/revisions", perm(auth.PermCourseView, authHandler.CourseFromTask, contentHandler.ListRevisions))
+	mux.HandleFunc("GET /admin/tasks/{task_id}/revisions/diff", perm(auth.PermCourseView, authHandler.CourseFromTask, contentHandler.DiffRevisions))
+	mux.HandleFunc("GET /admin/tasks/{task_id}/revisions/{version}", perm(auth.PermCourseView, authHandler.CourseFromTask, contentHandler.GetRevision))
+	mux.HandleFunc("POST /admin/tasks/{task_id}/revisions/{version}/rollback", perm(auth.PermContentEdit, authHandler.CourseFromTask, contentHandler.RollbackRevision))
+	mux.HandleFunc("GET /admin/steps/{step_id}/revisions", perm(auth.PermCourseView, authHandler.CourseFromStep, contentHandler.ListRevisions))
+	mux.HandleFunc("GET /admin/steps/{step_id}/revisions/diff", perm(auth.PermCourseView, authHandler.CourseFromStep, contentHandler.DiffRevisions))
+	mux.HandleFunc("GET /admin/steps/{step_id}/revisions/{version}", perm(auth.PermCourseView, authHandler.CourseFromStep, contentHandler.GetRevision))
+	mux.HandleFunc("POST /admin/steps/{step_id}/revisions/{version}/rollback", perm(auth.PermContentEdit, authHandler.CourseFromStep, contentHandler.RollbackRevision))
+
+	mux.HandleFunc("DELETE /admin/courses/{course_id}", perm(auth.PermCourseDelete, auth.CourseFromPath, contentHandler.DeleteCourse))
+	mux.HandleFunc("DELETE /admin/lessons/{lesson_id}", perm(auth.PermContentDelete, authHandler.CourseFromLesson, contentHandler.DeleteLesson))
+	mux.HandleFunc("DELETE /admin/tasks/{task_id}", perm(auth.PermContentDelete, authHandler.CourseFromTask, contentHandler.DeleteTask))
+	mux.HandleFunc("DELETE /admin/steps/{step_id}", perm(auth.PermContentDelete, authHandler.CourseFromStep, contentHandler.DeleteTaskStep))
 
-	// Admin Routes
-	mux.HandleFunc("POST /admin/courses", authHandler.MiddlewareAdmin(contentHandler.CreateCourse))
-	mux.HandleFunc("POST /admin/courses/{course_id}/lessons", authHandler.MiddlewareAdmin(contentHandler.CreateLesson))
-	mux.HandleFunc("POST /admin/lessons/{lesson_id}/task", authHandler.MiddlewareAdmin(contentHandler.CreateTask))
+	mux.HandleFunc("GET /admin/trash", perm(auth.PermCourseView, nil, contentHandler.ListTrash))
+	mux.HandleFunc("GET /admin/courses/{course_id}/trash", perm(auth.PermCourseView, auth.CourseFromPath, contentHandler.ListCourseTrash))
+	mux.HandleFunc("POST /admin/courses/{course_id}/restore", perm(auth.PermCourseDelete, auth.CourseFromPath, contentHandler.RestoreCourse))
+	mux.HandleFunc("POST /admin/lessons/{lesson_id}/restore", perm(auth.PermContentDelete, authHandler.CourseFromLesson, contentHandler.RestoreLesson))
+	mux.HandleFunc("POST /admin/tasks/{task_id}/restore", perm(auth.PermContentDelete, authHandler.CourseFromTask, contentHandler.RestoreTask))
+	mux.HandleFunc("POST /admin/steps/{step_id}/restore", perm(auth.PermContentDelete, authHandler.CourseFromStep, contentHandler.RestoreTaskStep))
 
-	mux.HandleFunc("DELETE /admin/courses/{course_id}", authHandler.MiddlewareAdmin(contentHandler.DeleteCourse))
-	mux.HandleFunc("DELETE /admin/lessons/{lesson_id}", authHandler.MiddlewareAdmin(contentHandler.DeleteLesson))
-	mux.HandleFunc("DELETE /admin/tasks/{task_id}", authHandler.MiddlewareAdmin(contentHandler.DeleteTask))
+	mux.HandleFunc("GET /admin/lockouts", perm(auth.PermUsersManage, nil, authHandler.ListLockouts))
+	mux.HandleFunc("DELETE /admin/lockouts/{key}", perm(auth.PermUsersManage, nil, authHandler.ClearLockout))
+	mux.HandleFunc("GET /admin/users", perm(auth.PermUsersManage, nil, authHandler.ListUsers))
+	mux.HandleFunc("GET /admin/users/{user_id}/progress", perm(auth.PermUsersManage, nil, authHandler.GetUserProgress))
+	mux.HandleFunc("PUT /admin/users/{user_id}/role", perm(auth.PermUsersManage, nil, authHandler.SetUserRole))
+	mux.HandleFunc("POST /admin/users/{user_id}/disable", perm(auth.PermUsersManage, nil, authHandler.DisableUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/enable", perm(auth.PermUsersManage, nil, authHandler.EnableUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/password-reset", perm(auth.PermUsersManage, nil, authHandler.ForcePasswordReset))
+	mux.HandleFunc("DELETE /admin/users/{user_id}", perm(auth.PermUsersManage, nil, authHandler.DeleteUser))
+	mux.HandleFunc("GET /admin/users/{user_id}/roles", perm(auth.PermUsersManage, nil, authHandler.ListRoleGrants))
+	mux.HandleFunc("POST /admin/users/{user_id}/roles", perm(auth.PermUsersManage, nil, authHandler.GrantRole))
+	mux.HandleFunc("DELETE /admin/roles/{grant_id}", perm(auth.PermUsersManage, nil, authHandler.RevokeRole))
 
 	log.Println("Server starting on :8080")
 	err = http.ListenAndServe(":8080", enableCORS(mux))
@@ -78,7 +239,7 @@ func enableCORS(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173") // Vite default port
 
 		// Allow specific methods (GET, POST, etc.)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 
 		// Allow specific headers (Content-Type for JSON, Authorization for Tokens)
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -1,13 +1,23 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyPrefix       = "tl_"
+	apiKeyDisplayChars = len(apiKeyPrefix) + 8
 )
 
 func generateRandomKey() (string, error) {
@@ -18,26 +28,187 @@ func generateRandomKey() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-func (h *Handler) GenerateAPIKey(w http.ResponseWriter, r *http.Request, user database.User) {
-	newKey, err := generateRandomKey()
+// APIKeyResponse describes a key without its secret.
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func newAPIKeyResponse(key database.ApiKey) APIKeyResponse {
+	res := APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    strings.Fields(key.Scopes),
+		CreatedAt: key.CreatedAt,
+	}
+	if key.LastUsedAt.Valid {
+		res.LastUsedAt = &key.LastUsedAt.Time
+	}
+	if key.ExpiresAt.Valid {
+		res.ExpiresAt = &key.ExpiresAt.Time
+	}
+	return res
+}
+
+// createAPIKey stores a new key for user through q and returns it together
+// with the secret, which is not recoverable afterwards.
+func createAPIKey(ctx context.Context, q *database.Queries, user database.User, name string, scopes []string, expiresAt *time.Time) (database.ApiKey, string, error) {
+	random, err := generateRandomKey()
+	if err != nil {
+		return database.ApiKey{}, "", err
+	}
+	secret := apiKeyPrefix + random
+
+	params := database.CreateAPIKeyParams{
+		UserID:  user.ID,
+		Name:    name,
+		Prefix:  secret[:apiKeyDisplayChars],
+		KeyHash: HashToken(secret),
+		Scopes:  strings.Join(scopes, " "),
+	}
+	if expiresAt != nil {
+		params.ExpiresAt = sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+	}
+
+	key, err := q.CreateAPIKey(ctx, params)
+	return key, secret, err
+}
+
+// CreateAPIKey creates a named key. Scopes default to DefaultScopes and the
+// key never expires unless expires_at is given.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	staff, err := h.isStaff(r.Context(), user)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	var problems []string
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" {
+		problems = append(problems, "name must not be empty")
+	}
+	if params.Scopes == nil {
+		params.Scopes = DefaultScopes
+	}
+	if len(params.Scopes) == 0 {
+		problems = append(problems, "scopes must not be empty")
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(AllScopes, scope) {
+			problems = append(problems, "unknown scope "+scope)
+		} else if scope == ScopeAdmin && !staff {
+			problems = append(problems, "only admins and staff with a role can create keys with the admin scope")
+		}
+	}
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		problems = append(problems, "expires_at must be in the future")
+	}
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Invalid API key",
+			"errors": problems,
+		})
+		return
+	}
+
+	// Store scopes deduplicated and in a fixed order
+	var scopes []string
+	for _, scope := range AllScopes {
+		if slices.Contains(params.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, secret, err := createAPIKey(r.Context(), h.DB, user, params.Name, scopes, params.ExpiresAt)
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
 
-	_, err = h.DB.UpdateAPIKey(r.Context(), database.UpdateAPIKeyParams{
-		ID: user.ID,
-		ApiKey: sql.NullString{
-			String: newKey,
-			Valid:  true,
-		},
+	type response struct {
+		APIKeyResponse
+		Key string `json:"key"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(response{newAPIKeyResponse(key), secret})
+}
+
+// ListAPIKeys lists the logged-in user's keys, without their secrets.
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request, user database.User) {
+	keys, err := h.DB.GetAPIKeysByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, newAPIKeyResponse(key))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// RevokeAPIKey deletes one of the logged-in user's keys.
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request, user database.User) {
+	keyID, err := uuid.Parse(r.PathValue("key_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	deleted, err := h.DB.DeleteAPIKey(r.Context(), database.DeleteAPIKeyParams{
+		ID:     keyID,
+		UserID: user.ID,
 	})
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "API key not found"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// GenerateAPIKey is the original single-key endpoint used by the web
+// terminal. It now adds a new key with the default scopes instead of
+// replacing the previous one.
+func (h *Handler) GenerateAPIKey(w http.ResponseWriter, r *http.Request, user database.User) {
+	_, secret, err := createAPIKey(r.Context(), h.DB, user, "cli", DefaultScopes, nil)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(201)
-	json.NewEncoder(w).Encode(map[string]string{"api_key": newKey})
+	json.NewEncoder(w).Encode(map[string]string{"api_key": secret})
 }
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// Device authorization flow (RFC 8628) for the CLI:
+//
+//  1. The CLI calls POST /auth/device/code and shows the user_code.
+//  2. The user, logged into the web terminal, approves the code.
+//  3. Meanwhile the CLI polls POST /auth/device/token with the device_code
+//     and receives an API key once the code is approved.
+const (
+	deviceCodeTTL   = 10 * time.Minute
+	devicePollEvery = 5 * time.Second
+
+	// No vowels, so codes can't spell words, and no look-alike characters
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+func generateUserCode() (string, error) {
+	b := make([]byte, userCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	for i, c := range b {
+		if i == userCodeLength/2 {
+			code.WriteByte('-')
+		}
+		// 256 is not a multiple of 20, the slight bias doesn't matter for
+		// a code that lives ten minutes
+		code.WriteByte(userCodeAlphabet[int(c)%len(userCodeAlphabet)])
+	}
+	return code.String(), nil
+}
+
+// normalizeUserCode accepts codes typed in lowercase or without the dash.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+func writeDeviceError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// RequestDeviceCode starts a device login. The CLI may name itself and ask
+// for specific scopes; the defaults match what the CLI needs.
+func (h *Handler) RequestDeviceCode(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ClientName string   `json:"client_name"`
+		Scopes     []string `json:"scopes"`
+	}
+
+	params := parameters{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+	}
+	if strings.TrimSpace(params.ClientName) == "" {
+		params.ClientName = "cli"
+	}
+	if params.Scopes == nil {
+		params.Scopes = DefaultScopes
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(AllScopes, scope) {
+			writeDeviceError(w, "invalid_scope")
+			return
+		}
+	}
+
+	deviceCode, err := generateRandomKey()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := h.DB.DeleteExpiredDeviceCodes(r.Context()); err != nil {
+		log.Printf("Error deleting expired device codes: %s", err)
+	}
+
+	_, err = h.DB.CreateDeviceCode(r.Context(), database.CreateDeviceCodeParams{
+		DeviceCodeHash: HashToken(deviceCode),
+		UserCode:       userCode,
+		ClientName:     strings.TrimSpace(params.ClientName),
+		Scopes:         strings.Join(params.Scopes, " "),
+		ExpiresAt:      time.Now().UTC().Add(deviceCodeTTL),
+	})
+	if err != nil {
+		// Most likely a user code collision, the CLI can simply retry
+		log.Printf("Error creating device code: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	type response struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(response{
+		DeviceCode:      deviceCode,
+		UserCode:        userCode,
+		VerificationURI: h.FrontendURL,
+		ExpiresIn:       int(deviceCodeTTL.Seconds()),
+		Interval:        int(devicePollEvery.Seconds()),
+	})
+}
+
+// PollDeviceToken is polled by the CLI. It answers with the RFC 8628 error
+// codes until the user acts on the code, then hands out the API key once.
+func (h *Handler) PollDeviceToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		DeviceCode string `json:"device_code"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.DeviceCode == "" {
+		writeDeviceError(w, "invalid_request")
+		return
+	}
+
+	device, err := h.DB.GetDeviceCodeByHash(r.Context(), HashToken(params.DeviceCode))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeDeviceError(w, "expired_token")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	switch {
+	case device.DeniedAt.Valid:
+		h.DB.DeleteDeviceCode(r.Context(), device.ID)
+		writeDeviceError(w, "access_denied")
+		return
+	case !device.ApprovedAt.Valid && time.Now().UTC().After(device.ExpiresAt):
+		writeDeviceError(w, "expired_token")
+		return
+	case !device.ApprovedAt.Valid:
+		tooSoon := device.LastPolledAt.Valid && time.Now().UTC().Sub(device.LastPolledAt.Time) < devicePollEvery
+		if err := h.DB.TouchDeviceCode(r.Context(), device.ID); err != nil {
+			log.Printf("Error updating device code: %s", err)
+		}
+		if tooSoon {
+			writeDeviceError(w, "slow_down")
+		} else {
+			writeDeviceError(w, "authorization_pending")
+		}
+		return
+	}
+
+	user, err := h.DB.GetUserByID(r.Context(), device.UserID.UUID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// Claiming the code and creating the key happen together, so two
+	// concurrent polls can't both get a key and a failure doesn't use up
+	// the code
+	var secret string
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		claimed, err := q.DeleteDeviceCode(r.Context(), device.ID)
+		if err != nil {
+			return err
+		}
+		if claimed == 0 {
+			return sql.ErrNoRows
+		}
+		_, secret, err = createAPIKey(r.Context(), q, user, device.ClientName, strings.Fields(device.Scopes), nil)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeDeviceError(w, "expired_token")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_key":  secret,
+		"username": user.Username,
+		"scopes":   strings.Fields(device.Scopes),
+	})
+}
+
+// GetDeviceCode shows a pending code to the logged-in user so they can
+// check who is asking for access before approving it.
+func (h *Handler) GetDeviceCode(w http.ResponseWriter, r *http.Request, user database.User) {
+	device, err := h.DB.GetPendingDeviceCode(r.Context(), normalizeUserCode(r.PathValue("user_code")))
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown or expired code"}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_code":   device.UserCode,
+		"client_name": device.ClientName,
+		"scopes":      strings.Fields(device.Scopes),
+		"expires_at":  device.ExpiresAt,
+	})
+}
+
+// ApproveDeviceCode grants the waiting CLI an API key for the logged-in user.
+func (h *Handler) ApproveDeviceCode(w http.ResponseWriter, r *http.Request, user database.User) {
+	userCode := normalizeUserCode(r.PathValue("user_code"))
+
+	device, err := h.DB.GetPendingDeviceCode(r.Context(), userCode)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown or expired code"}`))
+		return
+	}
+	if slices.Contains(strings.Fields(device.Scopes), ScopeAdmin) {
+		// The same rule as for keys created with CreateAPIKey
+		staff, err := h.isStaff(r.Context(), user)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if !staff {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Only admins and staff with a role can approve the admin scope"}`))
+			return
+		}
+	}
+
+	approved, err := h.DB.ApproveDeviceCode(r.Context(), database.ApproveDeviceCodeParams{
+		UserCode: userCode,
+		UserID:   uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if approved == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown or expired code"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// DenyDeviceCode rejects a code, the CLI gets access_denied on its next poll.
+func (h *Handler) DenyDeviceCode(w http.ResponseWriter, r *http.Request, user database.User) {
+	denied, err := h.DB.DenyDeviceCode(r.Context(), database.DenyDeviceCodeParams{
+		UserCode: normalizeUserCode(r.PathValue("user_code")),
+		UserID:   uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if denied == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown or expired code"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted for signing tokens.
+const minRSABits = 2048
+
+// KeySet holds the key tokens are signed with and every key they are
+// accepted from. Keeping the previous signing key in the verification set
+// for a while lets keys be rotated without logging everyone out.
+type KeySet struct {
+	signing signingKey
+	verify  map[string]verifyKey // By kid
+}
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+}
+
+type verifyKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+	jwk    map[string]string
+}
+
+// KeySetFromEnv loads the signing key from the PEM file in
+// JWT_SIGNING_KEY_FILE and any older keys, still accepted for verification,
+// from the files listed in JWT_VERIFY_KEY_FILES. RSA and Ed25519 keys are
+// supported, old keys may be given as public or private keys.
+func KeySetFromEnv() (*KeySet, error) {
+	path := os.Getenv("JWT_SIGNING_KEY_FILE")
+	if path == "" {
+		return nil, errors.New("auth: JWT_SIGNING_KEY_FILE is not set, create a key with 'make jwt-key'")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading signing key: %w", err)
+	}
+	private, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %s: %w", path, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("auth: %s: unsupported key type %T, use RSA or Ed25519", path, private)
+	}
+	signing, err := newVerifyKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("auth: %s: %w", path, err)
+	}
+
+	keys := &KeySet{verify: make(map[string]verifyKey)}
+	kid := signing.jwk["kid"]
+	keys.signing = signingKey{kid: kid, method: signing.method, private: private}
+	keys.verify[kid] = signing
+
+	for _, path := range strings.Fields(strings.ReplaceAll(os.Getenv("JWT_VERIFY_KEY_FILES"), ",", " ")) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading verification key: %w", err)
+		}
+		public, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", path, err)
+		}
+		key, err := newVerifyKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", path, err)
+		}
+		keys.verify[key.jwk["kid"]] = key
+	}
+
+	return keys, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("not a PKCS #8 or PKCS #1 private key")
+}
+
+// parsePublicKey also accepts private keys, so an old signing key file can
+// be moved to the verification list as it is.
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	private, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, errors.New("not a public or private key")
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", private)
+	}
+	return signer.Public(), nil
+}
+
+// newVerifyKey picks the signing method for a public key and describes it
+// as a JWK. The kid is the key's RFC 7638 thumbprint, so it never has to be
+// configured and stays the same across restarts.
+func newVerifyKey(public crypto.PublicKey) (verifyKey, error) {
+	var key verifyKey
+	var thumbprint string
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return key, fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+		}
+		n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		key.method = jwt.SigningMethodRS256
+		key.jwk = map[string]string{"kty": "RSA", "alg": "RS256", "n": n, "e": e}
+		thumbprint = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, e, n)
+	case ed25519.PublicKey:
+		x := base64.RawURLEncoding.EncodeToString(pub)
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = map[string]string{"kty": "OKP", "alg": "EdDSA", "crv": "Ed25519", "x": x}
+		thumbprint = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, x)
+	default:
+		return key, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", public)
+	}
+
+	sum := sha256.Sum256([]byte(thumbprint))
+	key.public = public
+	key.jwk["kid"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	key.jwk["use"] = "sig"
+	return key, nil
+}
+
+// sign signs claims with the current signing key.
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.kid
+	return token.SignedString(k.signing.private)
+}
+
+// keyFunc finds the verification key named by a token's kid and makes sure
+// the token uses that key's algorithm.
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// validMethods limits parsing to the asymmetric methods KeySet produces.
+var validMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// JWKS publishes the verification keys so other services can check tokens
+// issued by this API.
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	keys := make([]map[string]string, 0, len(h.Tokens.Keys.verify))
+	// Current key first, some clients only look at the first one
+	keys = append(keys, h.Tokens.Keys.verify[h.Tokens.Keys.signing.kid].jwk)
+	for kid, key := range h.Tokens.Keys.verify {
+		if kid != h.Tokens.Keys.signing.kid {
+			keys = append(keys, key.jwk)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
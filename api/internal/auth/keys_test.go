@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func generateRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func generateEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func pkcs8(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func pkix(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func writeKeyFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testKeySet signs with signer and also accepts tokens of others.
+func testKeySet(t *testing.T, signer crypto.Signer, others ...crypto.Signer) *KeySet {
+	t.Helper()
+	keys := &KeySet{verify: make(map[string]verifyKey)}
+	for i, s := range append([]crypto.Signer{signer}, others...) {
+		key, err := newVerifyKey(s.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys.verify[key.jwk["kid"]] = key
+		if i == 0 {
+			keys.signing = signingKey{kid: key.jwk["kid"], method: key.method, private: s}
+		}
+	}
+	return keys
+}
+
+func TestKeySetFromEnv(t *testing.T) {
+	rsaKey := generateRSAKey(t, 2048)
+	edKey := generateEd25519Key(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantAlg string // Empty when loading must fail
+	}{
+		{"Ed25519 PKCS #8", pkcs8(t, edKey), "EdDSA"},
+		{"RSA PKCS #8", pkcs8(t, rsaKey), "RS256"},
+		{"RSA PKCS #1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), "RS256"},
+		{"RSA 1024 bits", pkcs8(t, generateRSAKey(t, 1024)), ""},
+		{"ECDSA", pkcs8(t, ecKey), ""},
+		{"X25519", pkcs8(t, x25519Key), ""},
+		{"public key", pkix(t, edKey.Public()), ""},
+		{"no PEM", []byte("not a key"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SIGNING_KEY_FILE", writeKeyFile(t, tt.pem))
+			t.Setenv("JWT_VERIFY_KEY_FILES", "")
+
+			keys, err := KeySetFromEnv()
+			if tt.wantAlg == "" {
+				if err == nil {
+					t.Fatal("key accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alg := keys.signing.method.Alg(); alg != tt.wantAlg {
+				t.Errorf("signing with %s, want %s", alg, tt.wantAlg)
+			}
+			if _, ok := keys.verify[keys.signing.kid]; !ok || len(keys.verify) != 1 {
+				t.Errorf("verification keys %v, want only the signing key %s", keys.verify, keys.signing.kid)
+			}
+		})
+	}
+
+	t.Run("not set", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEY_FILE", "")
+		if _, err := KeySetFromEnv(); err == nil {
+			t.Error("loaded without a key file")
+		}
+	})
+}
+
+func TestKeySetFromEnvVerifyKeys(t *testing.T) {
+	current := generateEd25519Key(t)
+	oldRSA := generateRSAKey(t, 2048)
+	oldEd := generateEd25519Key(t)
+
+	t.Setenv("JWT_SIGNING_KEY_FILE", writeKeyFile(t, pkcs8(t, current)))
+	// Old keys as a public key and as the private key file they were
+	t.Setenv("JWT_VERIFY_KEY_FILES", writeKeyFile(t, pkix(t, oldRSA.Public()))+", "+writeKeyFile(t, pkcs8(t, oldEd)))
+
+	keys, err := KeySetFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testKeySet(t, current, oldRSA, oldEd)
+	if keys.signing.kid != want.signing.kid {
+		t.Errorf("signing kid %s, want %s", keys.signing.kid, want.signing.kid)
+	}
+	for kid := range want.verify {
+		if _, ok := keys.verify[kid]; !ok {
+			t.Errorf("key %s not accepted for verification", kid)
+		}
+	}
+	if len(keys.verify) != len(want.verify) {
+		t.Errorf("%d verification keys, want %d", len(keys.verify), len(want.verify))
+	}
+}
+
+func TestKidIsThumbprint(t *testing.T) {
+	// RFC 8037 Appendix A.3
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newVerifyKey(ed25519.PublicKey(x))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid, want := key.jwk["kid"], "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; kid != want {
+		t.Errorf("kid = %s, want %s", kid, want)
+	}
+
+	// The same key always gets the same kid, other keys another one
+	rsaKey := generateRSAKey(t, 2048)
+	first, err := newVerifyKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := newVerifyKey(&rsa.PublicKey{N: new(big.Int).Set(rsaKey.N), E: rsaKey.E})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newVerifyKey(generateRSAKey(t, 2048).Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.jwk["kid"] != again.jwk["kid"] {
+		t.Error("kid changed for the same key")
+	}
+	if first.jwk["kid"] == other.jwk["kid"] {
+		t.Error("two keys share a kid")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	current := generateEd25519Key(t)
+	old := generateRSAKey(t, 2048)
+	h := &Handler{Tokens: &Tokens{Keys: testKeySet(t, current, old)}}
+
+	rec := httptest.NewRecorder()
+	h.JWKS(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	var body struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(body.Keys))
+	}
+
+	ed, rs := body.Keys[0], body.Keys[1]
+	if ed["kid"] != h.Tokens.Keys.signing.kid {
+		t.Errorf("first key is %s, want the signing key %s", ed["kid"], h.Tokens.Keys.signing.kid)
+	}
+	for _, key := range body.Keys {
+		if key["use"] != "sig" || key["d"] != "" || key["p"] != "" {
+			t.Errorf("key %v: want a public signing key", key)
+		}
+	}
+
+	x, _ := base64.RawURLEncoding.DecodeString(ed["x"])
+	if ed["kty"] != "OKP" || ed["crv"] != "Ed25519" || ed["alg"] != "EdDSA" || !current.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Errorf("Ed25519 key %v does not describe the signing key", ed)
+	}
+
+	n, _ := base64.RawURLEncoding.DecodeString(rs["n"])
+	e, _ := base64.RawURLEncoding.DecodeString(rs["e"])
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if rs["kty"] != "RSA" || rs["alg"] != "RS256" || !old.PublicKey.Equal(public) {
+		t.Errorf("RSA key %v does not describe the old key", rs)
+	}
+}
@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"os"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
 )
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -21,24 +24,84 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	keys := loginThrottleKeys(params.Username, h.clientIP(r))
+	wait, err := h.lockedFor(r.Context(), keys)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
 	user, err := h.DB.GetUserByUsername(r.Context(), params.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(500)
+		return
+	}
+
+	// Always run the password check so unknown users can't be told apart
+	// by response time
+	hash := user.PasswordHash
 	if err != nil {
+		hash = dummyHash()
+	}
+	match := CheckPassword(params.Password, hash)
+	if err != nil || !match {
+		h.recordLoginFailure(r.Context(), keys)
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	match := CheckPassword(params.Password, user.PasswordHash)
-	if !match {
-		w.WriteHeader(http.StatusUnauthorized)
+	h.challengeOrCompleteLogin(w, r, user, keys)
+}
+
+// challengeOrCompleteLogin is called once the user proved who they are. With
+// 2FA on that only earns a token for the second step, see LoginMFA.
+func (h *Handler) challengeOrCompleteLogin(w http.ResponseWriter, r *http.Request, user database.User, keys []throttleKey) {
+	mfa, err := h.totpEnabled(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if mfa {
+		mfaToken, err := h.Tokens.makeMFAToken(user.ID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	h.completeLogin(w, r, user, keys)
+}
+
+// completeLogin starts a session once every login step has passed.
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, keys []throttleKey) {
+	if user.DisabledAt.Valid {
+		writeAccountDisabled(w)
+		return
+	}
+	if user.PasswordResetRequired {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "A password reset is required, use the code that was emailed to you"}`))
 		return
 	}
 
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "my-secret-key"
+	// Only the account is cleared, or an attacker could reset their
+	// address's counter by logging into an account of their own
+	if _, err := h.DB.ClearLoginThrottle(r.Context(), keys[0].key); err != nil {
+		log.Printf("Error clearing login throttle: %s", err)
 	}
 
-	token, err := MakeJWT(user.ID, secret)
+	tokens, err := h.startSession(r.Context(), user)
 	if err != nil {
 		log.Printf("Error generating token: %s", err)
 		w.WriteHeader(500)
@@ -51,13 +114,13 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type loginResponse struct {
-		Token string       `json:"token"`
-		User  UserResponse `json:"user"`
+		tokenPair
+		User UserResponse `json:"user"`
 	}
 
-	// Send back Token + User Info
+	// Send back Tokens + User Info
 	response := loginResponse{
-		Token: token,
+		tokenPair: tokens,
 		User: UserResponse{
 			ID:       user.ID.String(),
 			Username: user.Username,
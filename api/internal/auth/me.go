@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+// GetMe returns the logged-in user's own account.
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	writeUser(w, user)
+}
+
+// UpdateMe changes the username and/or email. A new email address has to be
+// verified again, so a verification email is sent to it.
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Username *string `json:"username"`
+		Email    *string `json:"email"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	username, email := user.Username, user.Email
+	var problems []string
+	if params.Username != nil {
+		username = strings.TrimSpace(*params.Username)
+		if username == "" {
+			problems = append(problems, "username must not be empty")
+		}
+	}
+	if params.Email != nil {
+		email = strings.TrimSpace(*params.Email)
+		if !validEmail(email) {
+			problems = append(problems, "email is not a valid address")
+		}
+	}
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Invalid profile",
+			"errors": problems,
+		})
+		return
+	}
+
+	updated, err := h.DB.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		ID:       user.ID,
+		Username: username,
+		Email:    email,
+	})
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Username or email is already taken"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	if updated.Email != user.Email {
+		if err := h.sendVerificationEmail(r.Context(), updated); err != nil {
+			log.Printf("Error sending verification email: %s", err)
+		}
+	}
+
+	writeUser(w, updated)
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// session is revoked and the caller gets a fresh one in return.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	keys := loginThrottleKeys(user.Username, h.clientIP(r))
+	if !h.allowAttempt(w, r, keys) {
+		return
+	}
+	if !CheckPassword(params.OldPassword, user.PasswordHash) {
+		h.recordLoginFailure(r.Context(), keys)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Current password is wrong"}`))
+		return
+	}
+	if len(params.NewPassword) < minPasswordLength {
+		w.WriteHeader(422)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Password must be at least %d characters"}`, minPasswordLength)))
+		return
+	}
+
+	hash, err := HashPassword(params.NewPassword)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// The new password and the revoked sessions go together, or a failure
+	// could leave a stolen session working after the change
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+			ID:           user.ID,
+			PasswordHash: hash,
+		})
+		if err != nil {
+			return err
+		}
+		return q.RevokeUserSessions(r.Context(), user.ID)
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	tokens, err := h.startSession(r.Context(), user)
+	if err != nil {
+		log.Printf("Error generating token: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// ExportMe sends everything stored about the user as a JSON download.
+// Secrets such as the password hash and API key hashes are left out.
+func (h *Handler) ExportMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	keys, err := h.DB.GetAPIKeysByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	grants, err := h.DB.GetRoleGrantsByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	identities, err := h.DB.GetUserIdentitiesByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	completions, err := h.DB.GetTaskCompletionsByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	reads, err := h.DB.GetLessonReadsByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	submissions, err := h.DB.GetSubmissionsByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	mfa, err := h.totpEnabled(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	type export struct {
+		ExportedAt       time.Time                 `json:"exported_at"`
+		User             userResponse              `json:"user"`
+		TwoFactorEnabled bool                      `json:"two_factor_enabled"`
+		APIKeys          []APIKeyResponse          `json:"api_keys"`
+		RoleGrants       []database.RoleGrant      `json:"role_grants"`
+		Identities       []database.UserIdentity   `json:"identities"`
+		Completions      []database.TaskCompletion `json:"completions"`
+		LessonReads      []database.LessonRead     `json:"lesson_reads"`
+		Submissions      []database.Submission     `json:"submissions"`
+	}
+
+	out := export{
+		ExportedAt:       time.Now().UTC(),
+		User:             newUserResponse(user),
+		TwoFactorEnabled: mfa,
+		APIKeys:          make([]APIKeyResponse, 0, len(keys)),
+		RoleGrants:       grants,
+		Identities:       identities,
+		Completions:      completions,
+		LessonReads:      reads,
+		Submissions:      submissions,
+	}
+	for _, key := range keys {
+		out.APIKeys = append(out.APIKeys, newAPIKeyResponse(key))
+	}
+	if out.RoleGrants == nil {
+		out.RoleGrants = []database.RoleGrant{}
+	}
+	if out.Identities == nil {
+		out.Identities = []database.UserIdentity{}
+	}
+	if out.Completions == nil {
+		out.Completions = []database.TaskCompletion{}
+	}
+	if out.LessonReads == nil {
+		out.LessonReads = []database.LessonRead{}
+	}
+	if out.Submissions == nil {
+		out.Submissions = []database.Submission{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="t-learn-export.json"`)
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(out)
+}
+
+// DeleteMe deletes the account and everything that belongs to it. It needs
+// the password (see confirmIdentity), and a 2FA code when 2FA is enabled.
+func (h *Handler) DeleteMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	params := codeParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	keys := loginThrottleKeys(user.Username, h.clientIP(r))
+	if !h.allowAttempt(w, r, keys) {
+		return
+	}
+	mfa, err := h.totpEnabled(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	ok, err := h.confirmIdentity(r.Context(), user, params, mfa)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(r.Context(), keys)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if mfa {
+		ok, err := h.checkSecondFactor(r.Context(), user.ID, params.Code)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if !ok {
+			h.recordLoginFailure(r.Context(), keys)
+			w.WriteHeader(422)
+			w.Write([]byte(`{"error": "Invalid code"}`))
+			return
+		}
+	}
+
+	if _, err := h.DB.DeleteUser(r.Context(), user.ID); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// confirmIdentity checks the proof on top of the session that deleting the
+// account or turning off 2FA ask for: the password. Accounts created by SSO
+// have none, they send the reauth_code of a fresh SSO login instead, or
+// nothing when 2FA is on and the code the caller checks next has to do.
+func (h *Handler) confirmIdentity(ctx context.Context, user database.User, params codeParameters, mfa bool) (bool, error) {
+	if user.PasswordHash != "" {
+		return CheckPassword(params.Password, user.PasswordHash), nil
+	}
+	if params.ReauthCode == "" {
+		return mfa, nil
+	}
+
+	token, err := h.DB.ConsumeUserToken(ctx, database.ConsumeUserTokenParams{
+		TokenHash: HashToken(params.ReauthCode),
+		Purpose:   purposeSSOReauth,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return token.UserID == user.ID, nil
+}
@@ -1,9 +1,9 @@
 package auth
 
 import (
-	"database/sql"
+	"context"
+	"log"
 	"net/http"
-	"os"
 
 	"github.com/Tikkaaa3/t-learn/api/internal/database"
 )
@@ -19,41 +19,72 @@ func (h *Handler) MiddlewareAuth(handler AuthedHandler) http.HandlerFunc {
 		}
 
 		// Try to look up User by API Key first (for CLI)
-		user, err := h.DB.GetUserByAPIKey(r.Context(), sql.NullString{String: tokenString, Valid: true})
+		key, err := h.DB.GetAPIKeyByHash(r.Context(), HashToken(tokenString))
 		if err == nil {
-			handler(w, r, user)
-			return
-		}
+			user, err := h.DB.GetUserByID(r.Context(), key.UserID)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if user.DisabledAt.Valid {
+				writeAccountDisabled(w)
+				return
+			}
+			if err := h.DB.TouchAPIKey(r.Context(), key.ID); err != nil {
+				log.Printf("Error updating API key usage: %s", err)
+			}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret == "" {
-			jwtSecret = "my-secret-key"
+			ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+			handler(w, r.WithContext(ctx), user)
+			return
 		}
 
 		// Validate JWT
-		userID, err := ValidateJWT(tokenString, jwtSecret)
+		claims, err := h.Tokens.ValidateJWT(tokenString)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
-		user, err = h.DB.GetUserByID(r.Context(), userID)
+		// The session must still be active, this is what makes logout stick.
+		// It also loads the current role, the one in the token may be stale.
+		user, err := h.DB.GetUserBySession(r.Context(), database.GetUserBySessionParams{
+			ID:     claims.SessionUUID(),
+			UserID: claims.UserID(),
+		})
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
+		if user.DisabledAt.Valid {
+			writeAccountDisabled(w)
+			return
+		}
 
 		handler(w, r, user)
 	}
 }
 
-func (h *Handler) MiddlewareAdmin(handler AuthedHandler) http.HandlerFunc {
-	return h.MiddlewareAuth(func(w http.ResponseWriter, r *http.Request, user database.User) {
-		if user.Role != "admin" {
-			w.WriteHeader(http.StatusForbidden) // 403 Forbidden
-			w.Write([]byte("Access denied: Admins only"))
-			return
-		}
-		handler(w, r, user)
-	})
+func writeAccountDisabled(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(`{"error": "Account is disabled"}`))
+}
+
+// checkAdmin2FA writes a 403 and returns false when admins must have
+// two-factor authentication and user has not enabled it.
+func (h *Handler) checkAdmin2FA(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	if !h.RequireAdmin2FA {
+		return true
+	}
+	enabled, err := h.totpEnabled(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return false
+	}
+	if !enabled {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "Admins must enable two-factor authentication, see POST /auth/2fa/setup"}`))
+		return false
+	}
+	return true
 }
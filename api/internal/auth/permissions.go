@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// Permissions are checked by the admin endpoints. Admins have all of them,
+// other users get them through role grants, either for one course or, when
+// the grant has no course, for every course.
+const (
+	PermCourseView      = "course:view"      // Read course details, export
+	PermCourseCreate    = "course:create"    // Create or import courses, needs a grant without a course
+	PermCourseDelete    = "course:delete"    // Delete a whole course
+	PermContentEdit     = "content:edit"     // Create and update lessons, tasks and steps
+	PermContentDelete   = "content:delete"   // Delete lessons, tasks and steps
+	PermSubmissionsView = "submissions:view" // Read everyone's submissions
+	PermUsersManage     = "users:manage"     // Manage users, roles and lockouts, admins only
+)
+
+// Roles that can be granted on top of the user's own role.
+const (
+	RoleInstructor    = "instructor"
+	RoleTA            = "ta"
+	RoleContentEditor = "content-editor"
+)
+
+var rolePermissions = map[string][]string{
+	RoleInstructor: {
+		PermCourseView, PermCourseCreate, PermCourseDelete,
+		PermContentEdit, PermContentDelete, PermSubmissionsView,
+	},
+	RoleTA:            {PermCourseView, PermSubmissionsView},
+	RoleContentEditor: {PermCourseView, PermContentEdit},
+}
+
+// CourseResolver finds the course a request is about, so grants scoped to a
+// course can be checked. A nil resolver means the permission is global.
+type CourseResolver func(r *http.Request) (uuid.UUID, error)
+
+// CourseFromPath reads the course from the {course_id} path value.
+func CourseFromPath(r *http.Request) (uuid.UUID, error) {
+	return uuid.Parse(r.PathValue("course_id"))
+}
+
+// CourseFromLesson resolves the course of the {lesson_id} path value.
+func (h *Handler) CourseFromLesson(r *http.Request) (uuid.UUID, error) {
+	lessonID, err := uuid.Parse(r.PathValue("lesson_id"))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return h.DB.GetCourseIDByLessonID(r.Context(), lessonID)
+}
+
+// CourseFromTask resolves the course of the {task_id} path value.
+func (h *Handler) CourseFromTask(r *http.Request) (uuid.UUID, error) {
+	taskID, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return h.DB.GetCourseIDByTaskID(r.Context(), taskID)
+}
+
+// CourseFromStep resolves the course of the {step_id} path value.
+func (h *Handler) CourseFromStep(r *http.Request) (uuid.UUID, error) {
+	stepID, err := uuid.Parse(r.PathValue("step_id"))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return h.DB.GetCourseIDByStepID(r.Context(), stepID)
+}
+
+// can reports whether user has perm, either globally or, when courseID is
+// set, on that course.
+func (h *Handler) can(ctx context.Context, user database.User, perm string, courseID uuid.NullUUID) (bool, error) {
+	if user.Role == "admin" {
+		return true, nil
+	}
+
+	grants, err := h.DB.GetRoleGrantsByUserID(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, grant := range grants {
+		if grant.CourseID.Valid && (!courseID.Valid || grant.CourseID.UUID != courseID.UUID) {
+			continue
+		}
+		if slices.Contains(rolePermissions[grant.Role], perm) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isStaff reports whether user may use the admin endpoints at all, which
+// decides who can create API keys with the admin scope.
+func (h *Handler) isStaff(ctx context.Context, user database.User) (bool, error) {
+	if user.Role == "admin" {
+		return true, nil
+	}
+	grants, err := h.DB.GetRoleGrantsByUserID(ctx, user.ID)
+	return len(grants) > 0, err
+}
+
+// MiddlewarePermission lets the request through when the user has perm on
+// the course found by resolve. API keys also need the admin scope.
+func (h *Handler) MiddlewarePermission(perm string, resolve CourseResolver, handler AuthedHandler) http.HandlerFunc {
+	return h.MiddlewareAuth(RequireScope(ScopeAdmin, func(w http.ResponseWriter, r *http.Request, user database.User) {
+		var courseID uuid.NullUUID
+		if resolve != nil {
+			id, err := resolve(r)
+			if err != nil {
+				w.WriteHeader(404)
+				w.Write([]byte(`{"error": "Not found"}`))
+				return
+			}
+			courseID = uuid.NullUUID{UUID: id, Valid: true}
+		}
+
+		allowed, err := h.can(r.Context(), user, perm, courseID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if !allowed {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Missing the ` + perm + ` permission"}`))
+			return
+		}
+
+		if user.Role == "admin" && !h.checkAdmin2FA(w, r, user) {
+			return
+		}
+		handler(w, r, user)
+	}))
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// Purposes of the single-use tokens sent by email, see the user_tokens table.
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+
+	minPasswordLength = 8
+)
+
+// validEmail accepts a bare address like "ada@example.com", without a
+// display name.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// issueUserToken creates a token for purpose and invalidates any earlier one
+// with the same purpose, so only the latest email works.
+func (h *Handler) issueUserToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := generateRandomKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = h.DB.DeleteUserTokens(ctx, database.DeleteUserTokensParams{
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = h.DB.CreateUserToken(ctx, database.CreateUserTokenParams{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	return token, err
+}
+
+func (h *Handler) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := h.issueUserToken(ctx, user.ID, purposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your t-learn email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nplease confirm your email address by running this command in the t-learn terminal at %s:\n\n    verify %s\n\nThe code is valid for 24 hours.\n",
+			user.Username, h.FrontendURL, token,
+		),
+	})
+}
+
+// RequestEmailVerification sends a new verification email to the logged-in
+// user, replacing any earlier one.
+func (h *Handler) RequestEmailVerification(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.EmailVerifiedAt.Valid {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Email is already verified"}`))
+		return
+	}
+
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("Error sending verification email: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(202)
+}
+
+// VerifyEmail consumes a verification token.
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Token == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "token is required"}`))
+		return
+	}
+
+	token, err := h.DB.ConsumeUserToken(r.Context(), database.ConsumeUserTokenParams{
+		TokenHash: HashToken(params.Token),
+		Purpose:   purposeVerifyEmail,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "Invalid or expired token"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := h.DB.SetEmailVerified(r.Context(), token.UserID); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// RequestPasswordReset emails a reset token. It answers the same way, and
+// just as fast, whether or not the address belongs to an account, so it
+// can't be used to find out who is registered. Requests are throttled per
+// address and per client.
+func (h *Handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Email == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "email is required"}`))
+		return
+	}
+
+	keys := passwordResetThrottleKeys(params.Email, h.clientIP(r))
+	wait, err := h.lockedFor(r.Context(), keys)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if wait > 0 {
+		writeTooManyRequests(w, wait, "Too many password reset requests, try again later")
+		return
+	}
+	// Every request counts, as if it were a failed login
+	h.recordLoginFailure(r.Context(), keys)
+
+	user, err := h.DB.GetUserByEmail(r.Context(), params.Email)
+	if err == nil {
+		// Sending takes a while, in the background it can't give away that
+		// the address is registered
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), time.Minute)
+			defer cancel()
+			if err := h.sendPasswordReset(ctx, user); err != nil {
+				log.Printf("Error sending password reset email: %s", err)
+			}
+		}()
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error looking up user for password reset: %s", err)
+	}
+
+	w.WriteHeader(202)
+}
+
+func (h *Handler) sendPasswordReset(ctx context.Context, user database.User) error {
+	token, err := h.issueUserToken(ctx, user.ID, purposeResetPassword, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your t-learn password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nsomeone asked to reset the password of your t-learn account. To choose a new password, run this command in the t-learn terminal at %s:\n\n    reset %s <new password>\n\nThe code is valid for one hour. If you did not ask for this, you can ignore this email.\n",
+			user.Username, h.FrontendURL, token,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a reset token. Every session of
+// the user is revoked, so a stolen login stops working too.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Token == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "token is required"}`))
+		return
+	}
+	if len(params.Password) < minPasswordLength {
+		w.WriteHeader(422)
+		w.Write([]byte(fmt.Sprintf(`{"error": "Password must be at least %d characters"}`, minPasswordLength)))
+		return
+	}
+
+	hash, err := HashPassword(params.Password)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// The token is only spent when the password changes, and the password
+	// only changes along with the revoked sessions
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		token, err := q.ConsumeUserToken(r.Context(), database.ConsumeUserTokenParams{
+			TokenHash: HashToken(params.Token),
+			Purpose:   purposeResetPassword,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+			ID:           token.UserID,
+			PasswordHash: hash,
+		})
+		if err != nil {
+			return err
+		}
+		return q.RevokeUserSessions(r.Context(), token.UserID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "Invalid or expired token"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
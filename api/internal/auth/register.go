@@ -1,17 +1,41 @@
 package auth
 
 import (
+	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/mailer"
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc"
 	"github.com/google/uuid"
 )
 
 type Handler struct {
-	DB *database.Queries
+	DB   *database.Queries
+	Conn *sql.DB
+
+	// FrontendURL is where users are sent to approve device logins and to
+	// use the codes from verification and password reset emails.
+	FrontendURL string
+
+	Mailer mailer.Mailer
+
+	// Tokens signs access tokens and verifies the ones clients send back.
+	Tokens *Tokens
+
+	// Providers users can log in with instead of a password, by name.
+	Providers map[string]oidc.Provider
+
+	// TrustProxy makes login throttling use X-Forwarded-For as the client
+	// address. Only enable it behind a proxy that sets the header.
+	TrustProxy bool
+
+	// RequireAdmin2FA locks admins out of admin endpoints until they have
+	// enabled two-factor authentication.
+	RequireAdmin2FA bool
 }
 
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
@@ -30,6 +54,12 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validEmail(params.Email) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Invalid email address"}`))
+		return
+	}
+
 	hash, err := HashPassword(params.Password)
 	if err != nil {
 		log.Printf("Error hashing password: %s", err)
@@ -48,6 +78,12 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Registration still succeeds if the email can't be sent, the user can
+	// ask for a new one
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("Error sending verification email: %s", err)
+	}
+
 	type response struct {
 		ID        uuid.UUID `json:"id"`
 		CreatedAt time.Time `json:"created_at"`
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+var grantableRoles = []string{RoleInstructor, RoleTA, RoleContentEditor}
+
+// ListRoleGrants returns the roles granted to a user.
+func (h *Handler) ListRoleGrants(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	grants, err := h.DB.GetRoleGrantsByUserID(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if grants == nil {
+		grants = []database.RoleGrant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grants)
+}
+
+// GrantRole gives a user a role, on one course when course_id is set and on
+// every course otherwise.
+func (h *Handler) GrantRole(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Role     string     `json:"role"`
+		CourseID *uuid.UUID `json:"course_id"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if !slices.Contains(grantableRoles, params.Role) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Invalid role grant",
+			"errors": []string{"role must be one of instructor, ta or content-editor"},
+		})
+		return
+	}
+
+	var courseID uuid.NullUUID
+	if params.CourseID != nil {
+		courseID = uuid.NullUUID{UUID: *params.CourseID, Valid: true}
+	}
+
+	grant, err := h.DB.CreateRoleGrant(r.Context(), database.CreateRoleGrantParams{
+		UserID:    userID,
+		Role:      params.Role,
+		CourseID:  courseID,
+		GrantedBy: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "User already has this role"}`))
+		return
+	}
+	if isForeignKeyViolation(err) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User or course not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// RevokeRole removes a single role grant.
+func (h *Handler) RevokeRole(w http.ResponseWriter, r *http.Request, user database.User) {
+	grantID, err := uuid.Parse(r.PathValue("grant_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	deleted, err := h.DB.DeleteRoleGrant(r.Context(), grantID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Role grant not found"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
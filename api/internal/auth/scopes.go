@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+// Scopes limit what an API key can be used for. Logged in sessions are not
+// scoped and can do everything their role allows.
+const (
+	ScopeReadContent = "read-content" // Read lessons, tasks and own submissions
+	ScopeSubmit      = "submit"       // Submit or run tasks
+	ScopeAdmin       = "admin"        // Admin endpoints, only for admins
+)
+
+var AllScopes = []string{ScopeReadContent, ScopeSubmit, ScopeAdmin}
+
+// DefaultScopes are given to keys created without an explicit list, which is
+// what the CLI needs.
+var DefaultScopes = []string{ScopeReadContent, ScopeSubmit}
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+// apiKeyFromContext returns the API key the request was authenticated with,
+// if any.
+func apiKeyFromContext(ctx context.Context) (database.ApiKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(database.ApiKey)
+	return key, ok
+}
+
+func hasScope(ctx context.Context, scope string) bool {
+	key, ok := apiKeyFromContext(ctx)
+	if !ok {
+		return true
+	}
+	return slices.Contains(strings.Fields(key.Scopes), scope)
+}
+
+// RequireScope rejects requests made with an API key that lacks scope.
+func RequireScope(scope string, handler AuthedHandler) AuthedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if !hasScope(r.Context(), scope) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "API key is missing the ` + scope + ` scope"}`))
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// RequireSession rejects requests made with an API key, for endpoints that
+// need a real login such as managing keys.
+func RequireSession(handler AuthedHandler) AuthedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if _, ok := apiKeyFromContext(r.Context()); ok {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "This endpoint cannot be used with an API key"}`))
+			return
+		}
+		handler(w, r, user)
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+// tokenPair is what clients get back from login and refresh. The access
+// token is short lived, the refresh token is used to get a new pair.
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Seconds until Token expires
+}
+
+// startSession creates a session for a user who just logged in.
+func (h *Handler) startSession(ctx context.Context, user database.User) (tokenPair, error) {
+	refreshToken, err := MakeRefreshToken()
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	session, err := h.DB.CreateSession(ctx, database.CreateSessionParams{
+		UserID:           user.ID,
+		RefreshTokenHash: HashToken(refreshToken),
+		ExpiresAt:        time.Now().UTC().Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	return h.issueTokens(session, user, refreshToken)
+}
+
+func (h *Handler) issueTokens(session database.Session, user database.User, refreshToken string) (tokenPair, error) {
+	token, err := h.Tokens.MakeJWT(user.ID, session.ID, user.Role, AccessTokenTTL)
+	if err != nil {
+		return tokenPair{}, err
+	}
+	return tokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+var errAccountDisabled = errors.New("account is disabled")
+
+type refreshParameters struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working; presenting it again revokes
+// the whole session, since it means the token has leaked.
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	params := refreshParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.RefreshToken == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "refresh_token is required"}`))
+		return
+	}
+
+	newToken, err := MakeRefreshToken()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// The rotation is rolled back for a disabled user, the old token stays
+	// as it was and no new one is stored
+	oldHash := HashToken(params.RefreshToken)
+	var session database.Session
+	var user database.User
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		session, err = q.RotateSession(r.Context(), database.RotateSessionParams{
+			NewTokenHash: HashToken(newToken),
+			ExpiresAt:    time.Now().UTC().Add(RefreshTokenTTL),
+			OldTokenHash: oldHash,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The new token gets the user's current role
+		user, err = q.GetUserByID(r.Context(), session.UserID)
+		if err != nil {
+			return err
+		}
+		if user.DisabledAt.Valid {
+			return errAccountDisabled
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		revoked, err := h.DB.RevokeSessionByPreviousToken(r.Context(), sql.NullString{String: oldHash, Valid: true})
+		if err != nil {
+			log.Printf("Error revoking session: %s", err)
+		} else if revoked > 0 {
+			log.Printf("Refresh token reuse detected, session revoked")
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, errAccountDisabled) {
+		writeAccountDisabled(w)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	pair, err := h.issueTokens(session, user, newToken)
+	if err != nil {
+		log.Printf("Error generating token: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(pair)
+}
+
+// Logout revokes the session a refresh token belongs to. Access tokens of
+// that session are rejected from then on. Unknown tokens are ignored so
+// logging out twice is not an error.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	params := refreshParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.RefreshToken == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "refresh_token is required"}`))
+		return
+	}
+
+	if _, err := h.DB.RevokeSessionByToken(r.Context(), HashToken(params.RefreshToken)); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
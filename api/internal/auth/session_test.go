@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+func TestRefreshDisabledUser(t *testing.T) {
+	conn := testDB(t)
+	h := &Handler{DB: database.New(conn), Conn: conn, Tokens: testTokens(t)}
+	ctx := context.Background()
+
+	user, err := h.DB.CreateUser(ctx, database.CreateUserParams{
+		Username:     "ada",
+		Email:        "ada@example.com",
+		PasswordHash: "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := h.startSession(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := func() int {
+		rec := httptest.NewRecorder()
+		body := strings.NewReader(`{"refresh_token": "` + pair.RefreshToken + `"}`)
+		h.Refresh(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", body))
+		return rec.Code
+	}
+
+	if _, err := h.DB.DisableUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := refresh(); code != http.StatusForbidden {
+		t.Fatalf("refresh of a disabled user: status %d, want 403", code)
+	}
+
+	// The rejected refresh left the session alone, so the same token still
+	// works once the user is enabled again instead of counting as reused
+	if _, err := h.DB.EnableUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := refresh(); code != http.StatusOK {
+		t.Errorf("refresh after enabling: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,450 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc"
+	"github.com/google/uuid"
+)
+
+const (
+	// How long the user has to log in at the provider
+	oidcLoginTTL = 10 * time.Minute
+
+	// The callback hands the frontend a short-lived code instead of tokens,
+	// so no token ends up in a URL. See ExchangeSSOCode.
+	purposeSSOLogin = "sso_login"
+	ssoLoginTTL     = 2 * time.Minute
+
+	// A fresh SSO login stands in for the password of accounts without one,
+	// see confirmIdentity
+	purposeSSOReauth = "sso_reauth"
+	ssoReauthTTL     = 5 * time.Minute
+)
+
+var (
+	errSSONoEmail    = errors.New("the provider did not share an email address")
+	errSSOEmailTaken = errors.New("an account with this email already exists, log in with your password and link the provider from your account")
+)
+
+// ListSSOProviders returns the names of the configured login providers.
+func (h *Handler) ListSSOProviders(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.Providers))
+	for name := range h.Providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"providers": names})
+}
+
+// startSSO remembers a new login attempt and returns the provider URL the
+// browser has to visit. userID is set when linking a logged-in user, or with
+// reauth when the user logs in again to confirm it is them.
+func (h *Handler) startSSO(ctx context.Context, name string, provider oidc.Provider, userID uuid.NullUUID, reauth bool) (string, error) {
+	state, err := oidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.DB.DeleteExpiredOIDCLogins(ctx); err != nil {
+		log.Printf("Error deleting expired SSO logins: %s", err)
+	}
+
+	err = h.DB.CreateOIDCLogin(ctx, database.CreateOIDCLoginParams{
+		StateHash:    HashToken(state),
+		Provider:     name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		Reauth:       reauth,
+		ExpiresAt:    time.Now().UTC().Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier)), nil
+}
+
+// StartSSOLogin sends the browser to the provider's login page.
+func (h *Handler) StartSSOLogin(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	provider, ok := h.Providers[name]
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown login provider"}`))
+		return
+	}
+
+	authURL, err := h.startSSO(r.Context(), name, provider, uuid.NullUUID{}, false)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// LinkSSO starts linking a provider account to the logged-in user. It
+// answers with the URL to open, since a browser navigation can't carry the
+// Authorization header.
+func (h *Handler) LinkSSO(w http.ResponseWriter, r *http.Request, user database.User) {
+	name := r.PathValue("provider")
+	provider, ok := h.Providers[name]
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown login provider"}`))
+		return
+	}
+
+	authURL, err := h.startSSO(r.Context(), name, provider, uuid.NullUUID{UUID: user.ID, Valid: true}, false)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": authURL})
+}
+
+// ReauthSSO starts a fresh login with a provider already linked to the
+// logged-in user. The browser comes back with an sso_reauth code, which
+// accounts without a password use in place of it. Like LinkSSO it answers
+// with the URL to open.
+func (h *Handler) ReauthSSO(w http.ResponseWriter, r *http.Request, user database.User) {
+	name := r.PathValue("provider")
+	provider, ok := h.Providers[name]
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown login provider"}`))
+		return
+	}
+
+	authURL, err := h.startSSO(r.Context(), name, provider, uuid.NullUUID{UUID: user.ID, Valid: true}, true)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": authURL})
+}
+
+// SSOCallback is where the provider sends the browser back to. It finishes
+// the login, link or reauth and sends the browser on to the frontend, with
+// either sso_code, sso_linked, sso_reauth or sso_error in the query string.
+func (h *Handler) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	provider, ok := h.Providers[name]
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Unknown login provider"}`))
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("error") != "" {
+		h.redirectToFrontend(w, r, "sso_error", "the login was cancelled or refused by the provider")
+		return
+	}
+
+	login, err := h.DB.ConsumeOIDCLogin(r.Context(), database.ConsumeOIDCLoginParams{
+		StateHash: HashToken(q.Get("state")),
+		Provider:  name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		h.redirectToFrontend(w, r, "sso_error", "the login expired, please try again")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), q.Get("code"), login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("SSO login with %s failed: %s", name, err)
+		h.redirectToFrontend(w, r, "sso_error", "the provider's answer could not be verified")
+		return
+	}
+
+	if login.Reauth {
+		// Only a provider account already linked to the user proves it is them
+		user, err := h.DB.GetUserByIdentity(r.Context(), database.GetUserByIdentityParams{
+			Provider: name,
+			Subject:  identity.Subject,
+		})
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && user.ID != login.UserID.UUID) {
+			h.redirectToFrontend(w, r, "sso_error", "this "+name+" account is not linked to yours")
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		code, err := h.issueUserToken(r.Context(), user.ID, purposeSSOReauth, ssoReauthTTL)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		h.redirectToFrontend(w, r, "sso_reauth", code)
+		return
+	}
+
+	if login.UserID.Valid {
+		_, err := h.DB.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+			UserID:   login.UserID.UUID,
+			Provider: name,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+		if isUniqueViolation(err) {
+			h.redirectToFrontend(w, r, "sso_error", "this "+name+" account, or another one, is already linked")
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		h.redirectToFrontend(w, r, "sso_linked", name)
+		return
+	}
+
+	user, err := h.ssoUser(r.Context(), name, identity)
+	if errors.Is(err, errSSONoEmail) || errors.Is(err, errSSOEmailTaken) {
+		h.redirectToFrontend(w, r, "sso_error", err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error setting up SSO user: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	code, err := h.issueUserToken(r.Context(), user.ID, purposeSSOLogin, ssoLoginTTL)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	h.redirectToFrontend(w, r, "sso_code", code)
+}
+
+func (h *Handler) redirectToFrontend(w http.ResponseWriter, r *http.Request, key, value string) {
+	target := strings.TrimSuffix(h.FrontendURL, "/") + "/?" + url.Values{key: {value}}.Encode()
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// ssoUser finds the user an identity belongs to. Unknown identities are
+// linked to the account with the same email when both sides have verified
+// it, otherwise a new account is created.
+func (h *Handler) ssoUser(ctx context.Context, name string, identity *oidc.Identity) (database.User, error) {
+	user, err := h.DB.GetUserByIdentity(ctx, database.GetUserByIdentityParams{
+		Provider: name,
+		Subject:  identity.Subject,
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		return user, err
+	}
+
+	if !validEmail(identity.Email) {
+		return database.User{}, errSSONoEmail
+	}
+
+	user, err = h.DB.GetUserByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Anyone can register with someone else's address, only trust it
+		// when we have seen the owner confirm it
+		if !identity.EmailVerified || !user.EmailVerifiedAt.Valid {
+			return database.User{}, errSSOEmailTaken
+		}
+		_, err := h.DB.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+			UserID:   user.ID,
+			Provider: name,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+		if isUniqueViolation(err) {
+			return database.User{}, errSSOEmailTaken
+		}
+		return user, err
+	case !errors.Is(err, sql.ErrNoRows):
+		return database.User{}, err
+	}
+
+	// Usernames are unique, add a suffix when the wanted one is taken
+	base := ssoUsername(identity)
+	username := base
+	for attempt := 0; ; attempt++ {
+		user, err = h.createSSOUser(ctx, name, username, identity)
+		if !isUniqueViolation(err) || attempt == 4 {
+			return user, err
+		}
+		suffix, err := generateRandomKey()
+		if err != nil {
+			return database.User{}, err
+		}
+		username = fmt.Sprintf("%s-%s", base, suffix[:4])
+	}
+}
+
+// createSSOUser creates an account without a password. The user can set one
+// later through a password reset.
+func (h *Handler) createSSOUser(ctx context.Context, name, username string, identity *oidc.Identity) (database.User, error) {
+	var user database.User
+	err := h.withTx(ctx, func(q *database.Queries) error {
+		var err error
+		user, err = q.CreateUser(ctx, database.CreateUserParams{
+			Username:     username,
+			Email:        identity.Email,
+			PasswordHash: "", // Never matches, see CheckPassword
+		})
+		if err != nil {
+			return err
+		}
+		if identity.EmailVerified {
+			if err := q.SetEmailVerified(ctx, user.ID); err != nil {
+				return err
+			}
+		}
+		_, err = q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+			UserID:   user.ID,
+			Provider: name,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+		return err
+	})
+	return user, err
+}
+
+var nonUsernameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func ssoUsername(identity *oidc.Identity) string {
+	username := identity.PreferredUsername
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	username = nonUsernameChars.ReplaceAllString(username, "")
+	if len(username) > 32 {
+		username = username[:32]
+	}
+	if username == "" {
+		return "user"
+	}
+	return username
+}
+
+// ExchangeSSOCode trades the sso_code from SSOCallback for the same answer
+// as Login, so users with 2FA still have to enter a code.
+func (h *Handler) ExchangeSSOCode(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Code == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "code is required"}`))
+		return
+	}
+
+	token, err := h.DB.ConsumeUserToken(r.Context(), database.ConsumeUserTokenParams{
+		TokenHash: HashToken(params.Code),
+		Purpose:   purposeSSOLogin,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "Invalid or expired code"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	user, err := h.DB.GetUserByID(r.Context(), token.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	h.challengeOrCompleteLogin(w, r, user, loginThrottleKeys(user.Username, h.clientIP(r)))
+}
+
+// ListIdentities lists the provider accounts linked to the user.
+func (h *Handler) ListIdentities(w http.ResponseWriter, r *http.Request, user database.User) {
+	identities, err := h.DB.GetUserIdentitiesByUserID(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if identities == nil {
+		identities = []database.UserIdentity{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identities)
+}
+
+// UnlinkIdentity removes a linked provider account. The last one can only
+// be removed once the user has a password, or they couldn't log in anymore.
+func (h *Handler) UnlinkIdentity(w http.ResponseWriter, r *http.Request, user database.User) {
+	identityID, err := uuid.Parse(r.PathValue("identity_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if user.PasswordHash == "" {
+		identities, err := h.DB.GetUserIdentitiesByUserID(r.Context(), user.ID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if len(identities) <= 1 {
+			w.WriteHeader(409)
+			w.Write([]byte(`{"error": "Set a password with a password reset before unlinking your only login"}`))
+			return
+		}
+	}
+
+	deleted, err := h.DB.DeleteUserIdentity(r.Context(), database.DeleteUserIdentityParams{
+		ID:     identityID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Identity not found"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
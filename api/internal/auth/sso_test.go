@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc"
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc/oidctest"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// testDB migrates a fresh schema in the database at TEST_DATABASE_URL and
+// drops it when the test is done. Tests that need a database are skipped
+// without it.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	schema := fmt.Sprintf("test_%s", strings.ToLower(rand.Text()[:12]))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("pgx", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob("../../sql/schema/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(file), err)
+		}
+	}
+	return db
+}
+
+func testTokens(t *testing.T) *Tokens {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := newVerifyKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := key.jwk["kid"]
+	return &Tokens{
+		Keys: &KeySet{
+			signing: signingKey{kid: kid, method: key.method, private: private},
+			verify:  map[string]verifyKey{kid: key},
+		},
+		Issuer:   "http://t-learn.test",
+		Audience: "http://t-learn.test",
+	}
+}
+
+// ssoLogin runs a login through the provider like a browser and returns the
+// query the API sends the browser back to the frontend with.
+func ssoLogin(t *testing.T, client *http.Client, api string) url.Values {
+	t.Helper()
+	next := api + "/auth/sso/uni/login"
+	for range 3 { // API, provider, API callback
+		resp, err := client.Get(next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("GET %s: status %d, want 302", next, resp.StatusCode)
+		}
+		next = resp.Header.Get("Location")
+	}
+
+	location, err := url.Parse(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(next, "http://frontend.test/") {
+		t.Fatalf("ended at %s, want the frontend", next)
+	}
+	return location.Query()
+}
+
+func exchangeSSOCode(t *testing.T, api, code string) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := http.Post(api+"/auth/sso/token", "application/json", strings.NewReader(`{"code": "`+code+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestSSOLogin(t *testing.T) {
+	conn := testDB(t)
+
+	provider := oidctest.NewServer("t-learn", "s3cret")
+	defer provider.Close()
+
+	h := &Handler{
+		DB:          database.New(conn),
+		Conn:        conn,
+		FrontendURL: "http://frontend.test",
+		Tokens:      testTokens(t),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/sso/{provider}/login", h.StartSSOLogin)
+	mux.HandleFunc("GET /auth/sso/{provider}/callback", h.SSOCallback)
+	mux.HandleFunc("POST /auth/sso/token", h.ExchangeSSOCode)
+	api := httptest.NewServer(mux)
+	defer api.Close()
+
+	p, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  api.URL + "/auth/sso/uni/callback",
+	})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	h.Providers = map[string]oidc.Provider{"uni": p}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// The first login creates the account, the second finds it again
+	var userID interface{}
+	for i := range 2 {
+		query := ssoLogin(t, client, api.URL)
+		code := query.Get("sso_code")
+		if code == "" {
+			t.Fatalf("login %d: no sso_code, got %v", i+1, query)
+		}
+
+		status, body := exchangeSSOCode(t, api.URL, code)
+		if status != 200 || body["token"] == nil {
+			t.Fatalf("login %d: exchange status %d, body %v", i+1, status, body)
+		}
+		user, _ := body["user"].(map[string]interface{})
+		if user["username"] != "jane" {
+			t.Errorf("login %d: username = %v, want jane", i+1, user["username"])
+		}
+		if i == 1 && user["id"] != userID {
+			t.Errorf("second login got user %v, want %v", user["id"], userID)
+		}
+		userID = user["id"]
+
+		// Codes work once
+		if status, _ := exchangeSSOCode(t, api.URL, code); status != 400 {
+			t.Errorf("login %d: reusing the code gave status %d, want 400", i+1, status)
+		}
+	}
+}
+
+func TestConfirmIdentityWithoutPassword(t *testing.T) {
+	conn := testDB(t)
+	h := &Handler{DB: database.New(conn), Conn: conn}
+	ctx := context.Background()
+
+	var users [2]database.User
+	for i, name := range []string{"jane", "joe"} {
+		user, err := h.DB.CreateUser(ctx, database.CreateUserParams{
+			Username:     name,
+			Email:        name + "@example.com",
+			PasswordHash: "",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[i] = user
+	}
+	jane, joe := users[0], users[1]
+
+	code, err := h.issueUserToken(ctx, jane.ID, purposeSSOReauth, ssoReauthTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		user   database.User
+		params codeParameters
+		mfa    bool
+		want   bool
+	}{
+		{"empty password", jane, codeParameters{}, false, false},
+		{"2FA code checked instead", jane, codeParameters{}, true, true},
+		{"someone else's reauth code", joe, codeParameters{ReauthCode: code}, false, false},
+		{"unknown reauth code", jane, codeParameters{ReauthCode: "nope"}, false, false},
+	}
+	for _, tt := range tests {
+		ok, err := h.confirmIdentity(ctx, tt.user, tt.params, tt.mfa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: confirmed = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+
+	// Joe's attempt used the code up, a fresh one works once
+	code, err = h.issueUserToken(ctx, jane.ID, purposeSSOReauth, ssoReauthTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		ok, err := h.confirmIdentity(ctx, jane, codeParameters{ReauthCode: code}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("use %d of the reauth code: confirmed = %v, want %v", i+1, ok, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+// throttlePolicy decides how long a key is locked after a failed login. The
+// first few failures are free, after that the lockout doubles with every
+// failure up to max.
+type throttlePolicy struct {
+	freeAttempts int32
+	base         time.Duration
+	max          time.Duration
+}
+
+var (
+	// Per account, whether or not the username exists
+	accountPolicy = throttlePolicy{freeAttempts: 5, base: 30 * time.Second, max: time.Hour}
+	// Per client address, looser since many users may share one
+	ipPolicy = throttlePolicy{freeAttempts: 20, base: 30 * time.Second, max: time.Hour}
+
+	// Password reset requests count every request, not just failures, so
+	// nobody can flood an inbox or the mail server
+	resetEmailPolicy = throttlePolicy{freeAttempts: 3, base: 5 * time.Minute, max: time.Hour}
+	resetIPPolicy    = throttlePolicy{freeAttempts: 10, base: 5 * time.Minute, max: time.Hour}
+)
+
+func (p throttlePolicy) lockout(failures int32) time.Duration {
+	if failures < p.freeAttempts {
+		return 0
+	}
+	exp := failures - p.freeAttempts
+	if exp > 20 {
+		return p.max
+	}
+	return min(p.base<<exp, p.max)
+}
+
+type throttleKey struct {
+	key    string
+	policy throttlePolicy
+}
+
+func loginThrottleKeys(username, ip string) []throttleKey {
+	return []throttleKey{
+		{"user:" + strings.ToLower(username), accountPolicy},
+		{"ip:" + ip, ipPolicy},
+	}
+}
+
+func passwordResetThrottleKeys(email, ip string) []throttleKey {
+	return []throttleKey{
+		{"reset:" + strings.ToLower(email), resetEmailPolicy},
+		{"reset-ip:" + ip, resetIPPolicy},
+	}
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// trusted when the server runs behind a proxy that sets it.
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// lockedFor returns how long the longest active lockout among keys lasts.
+func (h *Handler) lockedFor(ctx context.Context, keys []throttleKey) (time.Duration, error) {
+	var wait time.Duration
+	for _, k := range keys {
+		throttle, err := h.DB.GetLoginThrottle(ctx, k.key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if throttle.LockedUntil.Valid {
+			wait = max(wait, throttle.LockedUntil.Time.Sub(time.Now().UTC()))
+		}
+	}
+	return wait, nil
+}
+
+// allowAttempt writes a 429 and returns false while one of keys is locked
+// out. Endpoints that check the password or a code of a logged-in user call
+// it first, so a stolen session can't be used to guess either.
+func (h *Handler) allowAttempt(w http.ResponseWriter, r *http.Request, keys []throttleKey) bool {
+	wait, err := h.lockedFor(r.Context(), keys)
+	if err != nil {
+		w.WriteHeader(500)
+		return false
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) recordLoginFailure(ctx context.Context, keys []throttleKey) {
+	for _, k := range keys {
+		throttle, err := h.DB.RecordLoginFailure(ctx, k.key)
+		if err != nil {
+			log.Printf("Error recording failed login: %s", err)
+			continue
+		}
+
+		lockout := k.policy.lockout(throttle.Failures)
+		if lockout == 0 {
+			continue
+		}
+		err = h.DB.SetLoginLockout(ctx, database.SetLoginLockoutParams{
+			ThrottleKey: k.key,
+			LockedUntil: sql.NullTime{Time: time.Now().UTC().Add(lockout), Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error locking %s: %s", k.key, err)
+		}
+	}
+}
+
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	writeTooManyRequests(w, wait, "Too many failed login attempts, try again later")
+}
+
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	seconds := int(wait.Round(time.Second).Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"error": "` + message + `"}`))
+}
+
+// dummyHash is checked against when the username does not exist, so that
+// unknown users take as long as wrong passwords.
+var dummyHash = sync.OnceValue(func() string {
+	hash, err := HashPassword("t-learn dummy password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// Admin
+
+// ListLockouts shows every account and address with recent failed logins.
+func (h *Handler) ListLockouts(w http.ResponseWriter, r *http.Request, user database.User) {
+	throttles, err := h.DB.ListLoginThrottles(r.Context())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if throttles == nil {
+		throttles = []database.LoginThrottle{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(throttles)
+}
+
+// ClearLockout forgets the failed logins of one key, e.g. user:alice or
+// ip:203.0.113.7, or the reset requests of reset:ada@example.com, which also
+// lifts its lockout.
+func (h *Handler) ClearLockout(w http.ResponseWriter, r *http.Request, user database.User) {
+	cleared, err := h.DB.ClearLoginThrottle(r.Context(), r.PathValue("key"))
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if cleared == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "No failed logins recorded for this key"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
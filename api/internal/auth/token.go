@@ -1,9 +1,12 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -11,42 +14,182 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, secret string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID.String(),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	}
+const (
+	// AccessTokenTTL is kept short because access tokens are only checked
+	// against the session table, not re-issued, until they expire.
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Tokens issues and checks the JWTs of this deployment. Tokens carry the
+// issuer and audience, so one signed by another deployment, or meant for
+// another service, is rejected even if it shares a key.
+type Tokens struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	// ClockSkew is how far the clocks of the issuer and the verifier may
+	// be apart when checking exp, nbf and iat.
+	ClockSkew time.Duration
 }
 
-func ValidateJWT(tokenString, secret string) (uuid.UUID, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// defaultClockSkew allows for a little drift between servers.
+const defaultClockSkew = 30 * time.Second
+
+// TokensFromEnv loads the signing keys, see KeySetFromEnv. The issuer is
+// JWT_ISSUER, or publicURL when unset, the audience JWT_AUDIENCE, or
+// "t-learn", and JWT_CLOCK_SKEW takes a duration such as "30s".
+func TokensFromEnv(publicURL string) (*Tokens, error) {
+	keys, err := KeySetFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tokens{
+		Keys:      keys,
+		Issuer:    os.Getenv("JWT_ISSUER"),
+		Audience:  os.Getenv("JWT_AUDIENCE"),
+		ClockSkew: defaultClockSkew,
+	}
+	if t.Issuer == "" {
+		t.Issuer = publicURL
+	}
+	if t.Audience == "" {
+		t.Audience = "t-learn"
+	}
+	if skew := os.Getenv("JWT_CLOCK_SKEW"); skew != "" {
+		t.ClockSkew, err = time.ParseDuration(skew)
+		if err != nil || t.ClockSkew < 0 {
+			return nil, fmt.Errorf("auth: invalid JWT_CLOCK_SKEW %q", skew)
 		}
-		return []byte(secret), nil
+	}
+	return t, nil
+}
+
+// Claims are the contents of the tokens we issue. The subject is the user
+// ID. Role is the user's role when the token was issued, for services that
+// only see the token; this API always checks the current role.
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+}
+
+// Validate is called by the parser after the standard checks. It makes the
+// claims the parser treats as optional mandatory.
+func (c *Claims) Validate() error {
+	if c.IssuedAt == nil {
+		return errors.New("token has no iat claim")
+	}
+	if c.ID == "" {
+		return errors.New("token has no jti claim")
+	}
+	if _, err := uuid.Parse(c.Subject); err != nil {
+		return errors.New("invalid subject in token")
+	}
+	return nil
+}
+
+// UserID is the user the token was issued to.
+func (c *Claims) UserID() uuid.UUID {
+	return uuid.MustParse(c.Subject) // Checked by Validate
+}
+
+// SessionUUID is the session an access token belongs to.
+func (c *Claims) SessionUUID() uuid.UUID {
+	return uuid.MustParse(c.SessionID) // Checked by ValidateJWT
+}
+
+func (t *Tokens) sign(userID uuid.UUID, expiresIn time.Duration, claims Claims) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    t.Issuer,
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{t.Audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        uuid.NewString(),
+	}
+	return t.Keys.sign(&claims)
+}
+
+func (t *Tokens) parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, t.Keys.keyFunc,
+		jwt.WithValidMethods(validMethods),
+		jwt.WithIssuer(t.Issuer),
+		jwt.WithAudience(t.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithNotBeforeRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(t.ClockSkew),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// MakeJWT issues an access token for userID that is tied to a session, so
+// revoking the session also invalidates the token.
+func (t *Tokens) MakeJWT(userID, sessionID uuid.UUID, role string, expiresIn time.Duration) (string, error) {
+	return t.sign(userID, expiresIn, Claims{
+		SessionID: sessionID.String(),
+		Role:      role,
 	})
+}
+
+// ValidateJWT checks the signature, issuer, audience and times of an access
+// token and returns its claims.
+func (t *Tokens) ValidateJWT(tokenString string) (*Claims, error) {
+	claims, err := t.parse(tokenString)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("not an access token")
 	}
+	if _, err := uuid.Parse(claims.SessionID); err != nil {
+		return nil, fmt.Errorf("sid not found or invalid format")
+	}
+	return claims, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userIDStr, ok := claims["user_id"].(string)
-		if !ok {
-			return uuid.Nil, fmt.Errorf("user_id not found or invalid format")
-		}
+// mfaTokenTTL is how long a user has to enter their 2FA code after the
+// password was accepted.
+const mfaTokenTTL = 5 * time.Minute
 
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			return uuid.Nil, fmt.Errorf("invalid UUID in token")
-		}
+// makeMFAToken proves that userID passed the password step of a login. It
+// has no session, so it can't be used as an access token.
+func (t *Tokens) makeMFAToken(userID uuid.UUID) (string, error) {
+	return t.sign(userID, mfaTokenTTL, Claims{Purpose: "mfa"})
+}
 
-		return userID, nil
+func (t *Tokens) validateMFAToken(tokenString string) (uuid.UUID, error) {
+	claims, err := t.parse(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Purpose != "mfa" {
+		return uuid.Nil, fmt.Errorf("invalid token")
 	}
+	return claims.UserID(), nil
+}
+
+// MakeRefreshToken returns a new random refresh token. Only its hash is
+// stored, see HashToken.
+func MakeRefreshToken() (string, error) {
+	return generateRandomKey()
+}
 
-	return uuid.Nil, fmt.Errorf("invalid token")
+// HashToken returns the hex encoded SHA-256 of a token. Refresh tokens are
+// long random strings, so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestValidateJWT(t *testing.T) {
+	current := generateEd25519Key(t)
+	old := generateRSAKey(t, 2048)
+	stranger := generateEd25519Key(t)
+
+	tokens := &Tokens{
+		Keys:      testKeySet(t, current, old),
+		Issuer:    "http://t-learn.test",
+		Audience:  "t-learn",
+		ClockSkew: 30 * time.Second,
+	}
+	userID, sessionID := uuid.New(), uuid.New()
+	now := time.Now()
+
+	// claims returns the claims of a valid access token, for the cases to
+	// break one of
+	claims := func() *Claims {
+		return &Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    tokens.Issuer,
+				Subject:   userID.String(),
+				Audience:  jwt.ClaimStrings{tokens.Audience},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+				NotBefore: jwt.NewNumericDate(now),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ID:        uuid.NewString(),
+			},
+			SessionID: sessionID.String(),
+		}
+	}
+	signWith := func(keys *KeySet, c *Claims) string {
+		t.Helper()
+		token, err := keys.sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	sign := func(change func(c *Claims)) string {
+		t.Helper()
+		c := claims()
+		change(c)
+		return signWith(tokens.Keys, c)
+	}
+
+	issued, err := tokens.MakeJWT(userID, sessionID, "admin", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, err := tokens.makeMFAToken(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key is still accepted, with its own algorithm
+	oldKeys := testKeySet(t, old)
+	byOldKey := signWith(oldKeys, claims())
+
+	// An RS256 token that names the Ed25519 key
+	wrongAlg := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+	wrongAlg.Header["kid"] = tokens.Keys.signing.kid
+	wrongAlgToken, err := wrongAlg.SignedString(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HS256 with the public key as secret, the classic algorithm confusion
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	hmac.Header["kid"] = tokens.Keys.signing.kid
+	hmacToken, err := hmac.SignedString([]byte(current.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, claims())
+	none.Header["kid"] = tokens.Keys.signing.kid
+	noneToken, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"issued by MakeJWT", issued, true},
+		{"valid claims", sign(func(c *Claims) {}), true},
+		{"signed by the old key", byOldKey, true},
+		{"expired within the clock skew", sign(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) }), true},
+
+		{"wrong issuer", sign(func(c *Claims) { c.Issuer = "http://other.test" }), false},
+		{"no issuer", sign(func(c *Claims) { c.Issuer = "" }), false},
+		{"wrong audience", sign(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-service"} }), false},
+		{"no audience", sign(func(c *Claims) { c.Audience = nil }), false},
+		{"expired", sign(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }), false},
+		{"no exp", sign(func(c *Claims) { c.ExpiresAt = nil }), false},
+		{"not valid yet", sign(func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }), false},
+		{"no nbf", sign(func(c *Claims) { c.NotBefore = nil }), false},
+		{"issued in the future", sign(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) }), false},
+		{"no iat", sign(func(c *Claims) { c.IssuedAt = nil }), false},
+		{"no jti", sign(func(c *Claims) { c.ID = "" }), false},
+		{"subject is not a user ID", sign(func(c *Claims) { c.Subject = "admin" }), false},
+		{"no session", sign(func(c *Claims) { c.SessionID = "" }), false},
+		{"MFA token", mfa, false},
+
+		{"unknown kid", signWith(testKeySet(t, stranger), claims()), false},
+		{"algorithm of another key", wrongAlgToken, false},
+		{"HS256", hmacToken, false},
+		{"alg none", noneToken, false},
+		{"garbage", "not.a.token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokens.ValidateJWT(tt.token)
+			if !tt.valid {
+				if err == nil {
+					t.Error("token accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token rejected: %v", err)
+			}
+			if got.UserID() != userID || got.SessionUUID() != sessionID {
+				t.Errorf("got user %s and session %s", got.UserID(), got.SessionUUID())
+			}
+		})
+	}
+}
+
+func TestMakeJWTClaims(t *testing.T) {
+	tokens := &Tokens{
+		Keys:     testKeySet(t, generateEd25519Key(t)),
+		Issuer:   "http://t-learn.test",
+		Audience: "t-learn",
+	}
+	userID, sessionID := uuid.New(), uuid.New()
+
+	token, err := tokens.MakeJWT(userID, sessionID, "admin", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.ValidateJWT(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Issuer != tokens.Issuer || len(claims.Audience) != 1 || claims.Audience[0] != tokens.Audience {
+		t.Errorf("iss %q, aud %v", claims.Issuer, claims.Audience)
+	}
+	if claims.Role != "admin" || claims.Purpose != "" {
+		t.Errorf("role %q, purpose %q", claims.Role, claims.Purpose)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != time.Minute {
+		t.Errorf("valid for %s, want 1m", ttl)
+	}
+
+	// Each token gets its own ID
+	second, err := tokens.MakeJWT(userID, sessionID, "admin", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondClaims, err := tokens.ValidateJWT(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID == secondClaims.ID {
+		t.Error("two tokens share a jti")
+	}
+
+	// MFA tokens only work as MFA tokens
+	mfa, err := tokens.makeMFAToken(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tokens.validateMFAToken(mfa); err != nil || got != userID {
+		t.Errorf("validateMFAToken = %s, %v", got, err)
+	}
+	if _, err := tokens.validateMFAToken(token); err == nil {
+		t.Error("access token accepted as MFA token")
+	}
+}
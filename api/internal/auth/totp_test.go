@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+// The SHA1 seed of RFC 6238 Appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes, ours are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		want := tt.want[2:]
+		if got := totpCode(key, totpStep(now)); got != want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, want)
+		}
+		if step, ok := checkTOTP(rfcSecret, want, now); !ok || step != totpStep(now) {
+			t.Errorf("checkTOTP at %d = %d, %v, want %d, true", tt.unix, step, ok, totpStep(now))
+		}
+	}
+}
+
+func TestCheckTOTPWindow(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1234567890, 0)
+	current := totpStep(now)
+
+	tests := []struct {
+		offset int64
+		want   bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, tt := range tests {
+		code := totpCode(key, current+tt.offset)
+		step, ok := checkTOTP(rfcSecret, code, now)
+		if ok != tt.want {
+			t.Errorf("code of step %+d accepted = %v, want %v", tt.offset, ok, tt.want)
+		}
+		if ok && step != current+tt.offset {
+			t.Errorf("code of step %+d matched step %+d", tt.offset, step-current)
+		}
+	}
+}
+
+func TestCheckTOTPInput(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1234567890, 0)
+	code := totpCode(key, totpStep(now))
+
+	if _, ok := checkTOTP(rfcSecret, code[:3]+" "+code[3:], now); !ok {
+		t.Error("code with a space rejected")
+	}
+	if _, ok := checkTOTP(rfcSecret, code[:5], now); ok {
+		t.Error("5 digit prefix accepted")
+	}
+	if _, ok := checkTOTP("not base32!", code, now); ok {
+		t.Error("code accepted with an invalid secret")
+	}
+}
+
+func TestCheckTOTPCodeRejectsReplay(t *testing.T) {
+	conn := testDB(t)
+	h := &Handler{DB: database.New(conn), Conn: conn}
+	ctx := context.Background()
+
+	user, err := h.DB.CreateUser(ctx, database.CreateUserParams{
+		Username:     "ada",
+		Email:        "ada@example.com",
+		PasswordHash: "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := h.DB.CreateTOTPSecret(ctx, database.CreateTOTPSecretParams{
+		UserID: user.ID,
+		Secret: rfcSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := totpStep(time.Now())
+
+	for i, want := range []bool{true, false} {
+		ok, err := h.checkTOTPCode(ctx, cred, totpCode(key, current))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("use %d of the current code accepted = %v, want %v", i+1, ok, want)
+		}
+	}
+
+	// Once a code was used, older ones are no good either
+	ok, err := h.checkTOTPCode(ctx, cred, totpCode(key, current-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("code of the previous step accepted after the current one was used")
+	}
+}
@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+const recoveryCodeCount = 10
+
+// totpEnabled reports whether the user has finished 2FA enrollment.
+func (h *Handler) totpEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	cred, err := h.DB.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cred.EnabledAt.Valid, nil
+}
+
+// checkTOTPCode accepts a current code from the user's authenticator app,
+// enrolled or not, and makes sure it can't be used a second time.
+func (h *Handler) checkTOTPCode(ctx context.Context, cred database.TotpCredential, code string) (bool, error) {
+	step, ok := checkTOTP(cred.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	used, err := h.DB.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		UserID:       cred.UserID,
+		LastUsedStep: step,
+	})
+	return used == 1, err
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code.
+func (h *Handler) checkSecondFactor(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	cred, err := h.DB.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !cred.EnabledAt.Valid {
+		return false, nil
+	}
+
+	if ok, err := h.checkTOTPCode(ctx, cred, code); ok || err != nil {
+		return ok, err
+	}
+
+	used, err := h.DB.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: HashToken(normalizeRecoveryCode(code)),
+	})
+	return used == 1, err
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// replaceRecoveryCodes throws away the user's recovery codes and creates a
+// fresh set, returned in the xxxxx-xxxxx form shown to the user.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]string, error) {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+
+		err := q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: HashToken(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+type codeParameters struct {
+	Code       string `json:"code"`
+	Password   string `json:"password"`
+	ReauthCode string `json:"reauth_code"` // For accounts without a password, see confirmIdentity
+}
+
+// LoginMFA is the second login step for users with 2FA enabled. It takes the
+// mfa_token returned by Login and a TOTP or recovery code.
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	userID, err := h.Tokens.validateMFAToken(params.MFAToken)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	user, err := h.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// Wrong codes count as failed logins, the password alone must not allow
+	// unlimited guesses
+	keys := loginThrottleKeys(user.Username, h.clientIP(r))
+	if !h.allowAttempt(w, r, keys) {
+		return
+	}
+
+	ok, err := h.checkSecondFactor(r.Context(), user.ID, params.Code)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(r.Context(), keys)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	h.completeLogin(w, r, user, keys)
+}
+
+// Get2FAStatus tells the logged-in user whether 2FA is on and how many
+// recovery codes they have left.
+func (h *Handler) Get2FAStatus(w http.ResponseWriter, r *http.Request, user database.User) {
+	enabled, err := h.totpEnabled(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	left, err := h.DB.CountUnusedRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":             enabled,
+		"required":            h.RequireAdmin2FA && user.Role == "admin",
+		"recovery_codes_left": left,
+	})
+}
+
+// Setup2FA starts enrollment with a new secret. 2FA is only switched on once
+// a code from the authenticator app is confirmed with Enable2FA.
+func (h *Handler) Setup2FA(w http.ResponseWriter, r *http.Request, user database.User) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	_, err = h.DB.CreateTOTPSecret(r.Context(), database.CreateTOTPSecretParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Two-factor authentication is already enabled"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": totpURI(secret, user.Username),
+	})
+}
+
+// Enable2FA confirms enrollment with a code from the authenticator app and
+// returns the recovery codes. They are only shown this once.
+func (h *Handler) Enable2FA(w http.ResponseWriter, r *http.Request, user database.User) {
+	params := codeParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	cred, err := h.DB.GetTOTPCredential(r.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Start with POST /auth/2fa/setup"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if cred.EnabledAt.Valid {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Two-factor authentication is already enabled"}`))
+		return
+	}
+
+	ok, err := h.checkTOTPCode(r.Context(), cred, params.Code)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if !ok {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Invalid code"}`))
+		return
+	}
+
+	var codes []string
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.EnableTOTP(r.Context(), user.ID); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(r.Context(), q, user.ID)
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, e.g. after most of
+// them were used. Requires a current TOTP code.
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, user database.User) {
+	params := codeParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	cred, err := h.DB.GetTOTPCredential(r.Context(), user.ID)
+	if err != nil || !cred.EnabledAt.Valid {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Two-factor authentication is not enabled"}`))
+		return
+	}
+
+	keys := loginThrottleKeys(user.Username, h.clientIP(r))
+	if !h.allowAttempt(w, r, keys) {
+		return
+	}
+	ok, err := h.checkTOTPCode(r.Context(), cred, params.Code)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(r.Context(), keys)
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Invalid code"}`))
+		return
+	}
+
+	var codes []string
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		codes, err = replaceRecoveryCodes(r.Context(), q, user.ID)
+		return err
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// Disable2FA turns 2FA off. It needs both the password (see
+// confirmIdentity) and a code, so a hijacked session alone can't remove it.
+func (h *Handler) Disable2FA(w http.ResponseWriter, r *http.Request, user database.User) {
+	params := codeParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if h.RequireAdmin2FA && user.Role == "admin" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "Two-factor authentication is required for admins"}`))
+		return
+	}
+
+	// Both checks count as failed logins
+	keys := loginThrottleKeys(user.Username, h.clientIP(r))
+	if !h.allowAttempt(w, r, keys) {
+		return
+	}
+	ok, err := h.confirmIdentity(r.Context(), user, params, true)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(r.Context(), keys)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	ok, err = h.checkSecondFactor(r.Context(), user.ID, params.Code)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(r.Context(), keys)
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Invalid code"}`))
+		return
+	}
+
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.DeleteRecoveryCodes(r.Context(), user.ID); err != nil {
+			return err
+		}
+		return q.DeleteTOTPCredential(r.Context(), user.ID)
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
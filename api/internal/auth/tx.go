@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+)
+
+// withTx runs fn against a transaction-bound copy of the queries and commits
+// only if fn succeeds.
+func (h *Handler) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := h.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(h.DB.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/paging"
+	"github.com/google/uuid"
+)
+
+// Roles stored on the user itself. Finer grained roles are granted per
+// course, see GrantRole.
+var userRoles = []string{"student", "admin"}
+
+// userResponse is what admins see of an account, without the password hash.
+type userResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	DisabledAt      *time.Time `json:"disabled_at"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	resp := userResponse{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      user.Role,
+	}
+	if user.EmailVerifiedAt.Valid {
+		resp.EmailVerifiedAt = &user.EmailVerifiedAt.Time
+	}
+	if user.DisabledAt.Valid {
+		resp.DisabledAt = &user.DisabledAt.Time
+	}
+	return resp
+}
+
+func writeUser(w http.ResponseWriter, user database.User) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(newUserResponse(user))
+}
+
+// targetUser reads {user_id} and refuses to let admins act on their own
+// account, so they can't lock themselves out.
+func targetUser(w http.ResponseWriter, r *http.Request, user database.User) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return uuid.Nil, false
+	}
+	if userID == user.ID {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "You cannot do this to your own account"}`))
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// ListUsers lists accounts, optionally filtered by ?q= on username or
+// email. Supports ?limit= (default 50, max 200) and ?offset=.
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request, user database.User) {
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit, offset := paging.Params(r)
+
+	users, err := h.DB.ListUsers(r.Context(), database.ListUsersParams{
+		Search:     search,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	total, err := h.DB.CountUsers(r.Context(), search)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	resp := make([]userResponse, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, newUserResponse(u))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": resp,
+		"total": total,
+	})
+}
+
+// GetUserProgress shows how many tasks a user has completed in every course.
+func (h *Handler) GetUserProgress(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	target, err := h.DB.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	progress, err := h.DB.GetUserProgress(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if progress == nil {
+		progress = []database.GetUserProgressRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":    newUserResponse(target),
+		"courses": progress,
+	})
+}
+
+// SetUserRole makes a user a student or an admin.
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := targetUser(w, r, user)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	if !slices.Contains(userRoles, params.Role) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "role must be student or admin"}`))
+		return
+	}
+
+	updated, err := h.DB.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: params.Role,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	writeUser(w, updated)
+}
+
+// DisableUser blocks an account. Its sessions are revoked and its API keys
+// are rejected until the account is enabled again.
+func (h *Handler) DisableUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := targetUser(w, r, user)
+	if !ok {
+		return
+	}
+
+	updated, err := h.DB.DisableUser(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if err := h.DB.RevokeUserSessions(r.Context(), userID); err != nil {
+		log.Printf("Error revoking sessions of disabled user: %s", err)
+	}
+
+	writeUser(w, updated)
+}
+
+// EnableUser lifts DisableUser.
+func (h *Handler) EnableUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	updated, err := h.DB.EnableUser(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	writeUser(w, updated)
+}
+
+// ForcePasswordReset logs a user out everywhere, deletes their API keys and
+// emails them a reset code. They can't log in again, with a password or SSO,
+// until they use it.
+func (h *Handler) ForcePasswordReset(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := targetUser(w, r, user)
+	if !ok {
+		return
+	}
+
+	target, err := h.DB.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.RequirePasswordReset(r.Context(), userID); err != nil {
+			return err
+		}
+		if err := q.RevokeUserSessions(r.Context(), userID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserAPIKeys(r.Context(), userID); err != nil {
+			return err
+		}
+		return q.DeleteUserDeviceCodes(r.Context(), userID)
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if err := h.sendPasswordReset(r.Context(), target); err != nil {
+		log.Printf("Error sending password reset email: %s", err)
+	}
+
+	w.WriteHeader(202)
+}
+
+// DeleteUser removes an account and everything that belongs to it.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := targetUser(w, r, user)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.DB.DeleteUser(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "User not found"}`))
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,183 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxBundleSize caps the total size of all files in a bundle.
+const MaxBundleSize = 10 << 20
+
+var ErrTooLarge = errors.New("bundle: bundle is too large")
+
+// ReadDir loads the bundle stored in dir.
+func ReadDir(dir string) (*Course, error) {
+	files := make(map[string][]byte)
+	total := 0
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		total += len(data)
+		if total > MaxBundleSize {
+			return ErrTooLarge
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(files)
+}
+
+// WriteDir stores course as a bundle in dir, creating it if needed.
+func WriteDir(dir string, course *Course) error {
+	files, err := Encode(course)
+	if err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		if !fs.ValidPath(name) {
+			return fmt.Errorf("bundle: invalid file name %q", name)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadArchive loads a bundle from a gzip compressed tarball. The files may
+// either sit at the root of the archive or inside a single top-level
+// directory, which is what "tar czf course.tar.gz python-basics/" produces.
+func ReadArchive(r io.Reader) (*Course, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	total := int64(0)
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("bundle: invalid file name %q", hdr.Name)
+		}
+
+		total += hdr.Size
+		if total > MaxBundleSize {
+			return nil, ErrTooLarge
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		files[name] = data
+	}
+
+	return Decode(stripRoot(files))
+}
+
+// stripRoot removes a shared top-level directory if the manifest is not at
+// the root of the archive.
+func stripRoot(files map[string][]byte) map[string][]byte {
+	if _, ok := files[ManifestName]; ok {
+		return files
+	}
+
+	for name := range files {
+		dir, file := path.Split(name)
+		if file != ManifestName || strings.Count(dir, "/") != 1 {
+			continue
+		}
+
+		stripped := make(map[string][]byte, len(files))
+		for n, data := range files {
+			if rest, ok := strings.CutPrefix(n, dir); ok {
+				stripped[rest] = data
+			}
+		}
+		return stripped
+	}
+	return files
+}
+
+// WriteArchive stores course as a gzip compressed tarball.
+func WriteArchive(w io.Writer, course *Course) error {
+	files, err := Encode(course)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,203 @@
+// Package bundle converts courses to and from their on-disk format.
+//
+// A course bundle is a directory (or a .tar.gz of one) laid out like this:
+//
+//	course.yaml
+//	lessons/01-hello-python.md
+//	lessons/02-variables-and-math.md
+//
+// course.yaml holds the course metadata and, for every lesson, its title,
+// position, the markdown file with its content and its tasks, if any:
+//
+//	title: Python Basics
+//	slug: python-basics
+//	description: Start your journey with Python 3.
+//	status: published
+//	publish_at: 2026-09-01T08:00:00Z
+//	lessons:
+//	  - title: Hello Python
+//	    slug: hello-python
+//	    position: 1
+//	    status: published
+//	    file: lessons/01-hello-python.md
+//	    tasks:
+//	      - position: 1
+//	        description: Create main.py that prints Hello Python.
+//	        steps:
+//	          - position: 1
+//	            command: python3 main.py
+//	            expected_output: Hello Python
+//	            match_mode: trimmed
+//
+// Bundles from before a lesson could have several tasks use a single task
+// key instead of the list, Decode still reads those.
+// Positions may be left out, in which case the order of the list is used.
+// Slugs are optional too and default to a slugified title. Without a
+// status the importer picks one, publish_at is only kept for published
+// courses and lessons.
+// Markdown files are stored byte for byte, so exporting a course and
+// importing it again gives back the same content.
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestName is the file every bundle must contain at its root.
+const ManifestName = "course.yaml"
+
+type Course struct {
+	Title       string     `yaml:"title"`
+	Slug        string     `yaml:"slug,omitempty"`
+	Description string     `yaml:"description"`
+	Status      string     `yaml:"status,omitempty"`
+	PublishAt   *time.Time `yaml:"publish_at,omitempty"`
+	Lessons     []Lesson   `yaml:"lessons"`
+}
+
+type Lesson struct {
+	Title     string     `yaml:"title"`
+	Slug      string     `yaml:"slug,omitempty"`
+	Position  int32      `yaml:"position,omitempty"`
+	Status    string     `yaml:"status,omitempty"`
+	PublishAt *time.Time `yaml:"publish_at,omitempty"`
+	File      string     `yaml:"file"`
+	Tasks     []Task     `yaml:"tasks,omitempty"`
+
+	// Task is the single task of older bundles, Decode moves it to Tasks.
+	Task *Task `yaml:"task,omitempty"`
+
+	// Content is the markdown read from File. It is not part of course.yaml.
+	Content string `yaml:"-"`
+}
+
+type Task struct {
+	Position    int32  `yaml:"position,omitempty"`
+	Description string `yaml:"description"`
+	Steps       []Step `yaml:"steps"`
+}
+
+type Step struct {
+	Position       int32   `yaml:"position,omitempty"`
+	Command        string  `yaml:"command"`
+	ExpectedOutput string  `yaml:"expected_output"`
+	MatchMode      string  `yaml:"match_mode,omitempty"`
+	Tolerance      float64 `yaml:"tolerance,omitempty"`
+}
+
+// Decode builds a course from the files of a bundle, keyed by their slash
+// separated path relative to the bundle root.
+func Decode(files map[string][]byte) (*Course, error) {
+	manifest, ok := files[ManifestName]
+	if !ok {
+		return nil, fmt.Errorf("bundle: %s not found", ManifestName)
+	}
+
+	var course Course
+	if err := yaml.Unmarshal(manifest, &course); err != nil {
+		return nil, fmt.Errorf("bundle: parsing %s: %w", ManifestName, err)
+	}
+	if strings.TrimSpace(course.Title) == "" {
+		return nil, errors.New("bundle: course title must not be empty")
+	}
+	if course.Slug == "" {
+		course.Slug = Slugify(course.Title)
+	}
+
+	for i := range course.Lessons {
+		lesson := &course.Lessons[i]
+		if lesson.Position == 0 {
+			lesson.Position = int32(i + 1)
+		}
+		if lesson.Slug == "" {
+			lesson.Slug = Slugify(lesson.Title)
+		}
+		if lesson.File == "" {
+			return nil, fmt.Errorf("bundle: lesson %q has no file", lesson.Title)
+		}
+
+		content, ok := files[path.Clean(lesson.File)]
+		if !ok {
+			return nil, fmt.Errorf("bundle: lesson %q: %s not found", lesson.Title, lesson.File)
+		}
+		lesson.Content = string(content)
+
+		if lesson.Task != nil {
+			if len(lesson.Tasks) > 0 {
+				return nil, fmt.Errorf("bundle: lesson %q has both task and tasks", lesson.Title)
+			}
+			lesson.Tasks = []Task{*lesson.Task}
+			lesson.Task = nil
+		}
+		for j := range lesson.Tasks {
+			task := &lesson.Tasks[j]
+			if task.Position == 0 {
+				task.Position = int32(j + 1)
+			}
+			for k := range task.Steps {
+				if task.Steps[k].Position == 0 {
+					task.Steps[k].Position = int32(k + 1)
+				}
+			}
+		}
+	}
+
+	return &course, nil
+}
+
+// Encode is the inverse of Decode. Lessons without a file name get one
+// derived from their position and title.
+func Encode(course *Course) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(course.Lessons)+1)
+
+	lessons := make([]Lesson, len(course.Lessons))
+	for i, lesson := range course.Lessons {
+		if lesson.File == "" {
+			name := lesson.Slug
+			if name == "" {
+				name = Slugify(lesson.Title)
+			}
+			lesson.File = fmt.Sprintf("lessons/%02d-%s.md", lesson.Position, name)
+		}
+		if _, taken := files[lesson.File]; taken {
+			return nil, fmt.Errorf("bundle: two lessons use the file %s", lesson.File)
+		}
+		files[lesson.File] = []byte(lesson.Content)
+		lessons[i] = lesson
+	}
+
+	manifest := *course
+	manifest.Lessons = lessons
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&manifest); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	files[ManifestName] = buf.Bytes()
+
+	return files, nil
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a title into a lowercase, dash separated file name part.
+func Slugify(title string) string {
+	slug := nonSlugChars.ReplaceAllString(strings.ToLower(title), "-")
+	slug = strings.Trim(slug, "-")
+	if slug == "" {
+		return "untitled"
+	}
+	return slug
+}
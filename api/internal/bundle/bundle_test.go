@@ -0,0 +1,215 @@
+package bundle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/bundle"
+)
+
+func testCourse() *bundle.Course {
+	publishAt := time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+	return &bundle.Course{
+		Title:       "Python Basics",
+		Slug:        "python-basics",
+		Description: "Start your journey with Python 3.",
+		Status:      "published",
+		PublishAt:   &publishAt,
+		Lessons: []bundle.Lesson{
+			{
+				Title:     "Hello Python",
+				Slug:      "hello-python",
+				Position:  1,
+				Status:    "published",
+				PublishAt: &publishAt,
+				File:      "lessons/01-hello-python.md",
+				// Looks like front matter, but is the lesson itself
+				Content: "---\ntitle: Not the manifest\nposition: 7\n---\n\n# Hello\r\n\nPrint `Hello Python`.  \n\n",
+				Tasks: []bundle.Task{{
+					Position:    1,
+					Description: "Create main.py that prints Hello Python.",
+					Steps: []bundle.Step{{
+						Position:       1,
+						Command:        "python3 main.py",
+						ExpectedOutput: "Hello Python\n",
+						MatchMode:      "trimmed",
+					}},
+				}},
+			},
+			{
+				Title:    "Reading only",
+				Slug:     "reading-only",
+				Position: 2,
+				Status:   "draft",
+				File:     "lessons/02-reading-only.md",
+				Content:  "No trailing newline",
+			},
+			{
+				Title:    "Math",
+				Slug:     "math",
+				Position: 3,
+				Status:   "archived",
+				File:     "lessons/03-math.md",
+				Content:  "",
+				Tasks: []bundle.Task{
+					{
+						Position:    1,
+						Description: "Print pi.",
+						Steps: []bundle.Step{
+							{Position: 1, Command: "python3 pi.py", ExpectedOutput: "3.14159", MatchMode: "numeric", Tolerance: 0.001},
+							{Position: 2, Command: "python3 json.py", ExpectedOutput: `{"a": [1, 2]}`, MatchMode: "json"},
+						},
+					},
+					{
+						Position:    2,
+						Description: "Match a pattern.",
+						Steps: []bundle.Step{
+							{Position: 1, Command: "python3 re.py", ExpectedOutput: `\d+ items: "quoted"`, MatchMode: "regex"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	want := testCourse()
+	dir := t.TempDir()
+	if err := bundle.WriteDir(dir, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bundle.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the course:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	want := testCourse()
+	var buf bytes.Buffer
+	if err := bundle.WriteArchive(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bundle.ReadArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the course:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	manifest := `
+title: Go Basics
+description: Learn Go.
+lessons:
+  - title: First Steps!
+    file: a.md
+    task:
+      description: Say hi.
+      steps:
+        - command: go run .
+          expected_output: hi
+        - command: go vet
+          expected_output: ""
+`
+	course, err := bundle.Decode(map[string][]byte{
+		bundle.ManifestName: []byte(manifest),
+		"a.md":              []byte("# First"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lesson := course.Lessons[0]
+	if course.Slug != "go-basics" || lesson.Slug != "first-steps" || lesson.Position != 1 {
+		t.Errorf("got slugs %q, %q and position %d", course.Slug, lesson.Slug, lesson.Position)
+	}
+	if lesson.Task != nil || len(lesson.Tasks) != 1 || lesson.Tasks[0].Position != 1 {
+		t.Fatalf("single task not moved to tasks: %+v", lesson)
+	}
+	if steps := lesson.Tasks[0].Steps; steps[0].Position != 1 || steps[1].Position != 2 {
+		t.Errorf("got step positions %d and %d", steps[0].Position, steps[1].Position)
+	}
+}
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+func archive(t *testing.T, files ...archiveFile) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadArchiveStripsTopLevelDirectory(t *testing.T) {
+	buf := archive(t,
+		archiveFile{"python-basics/course.yaml", []byte("title: T\ndescription: D\nlessons:\n  - title: L\n    file: l.md\n")},
+		archiveFile{"python-basics/l.md", []byte("# L")},
+	)
+
+	course, err := bundle.ReadArchive(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if course.Lessons[0].Content != "# L" {
+		t.Errorf("got content %q", course.Lessons[0].Content)
+	}
+}
+
+func TestReadArchiveRejectsTooLarge(t *testing.T) {
+	buf := archive(t,
+		archiveFile{bundle.ManifestName, []byte("title: T\ndescription: D\nlessons: []\n")},
+		archiveFile{"big.md", make([]byte, bundle.MaxBundleSize)},
+	)
+
+	if _, err := bundle.ReadArchive(buf); !errors.Is(err, bundle.ErrTooLarge) {
+		t.Errorf("got %v, want ErrTooLarge", err)
+	}
+}
+
+func TestReadArchiveRejectsPathTraversal(t *testing.T) {
+	for _, name := range []string{"../course.yaml", "lessons/../../x.md", "/etc/passwd"} {
+		t.Run(name, func(t *testing.T) {
+			buf := archive(t,
+				archiveFile{bundle.ManifestName, []byte("title: T\ndescription: D\nlessons: []\n")},
+				archiveFile{name, []byte("x")},
+			)
+
+			if _, err := bundle.ReadArchive(buf); err == nil {
+				t.Error("archive accepted")
+			}
+		})
+	}
+}
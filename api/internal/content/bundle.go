@@ -0,0 +1,272 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/bundle"
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// ImportCourse creates a new course from a .tar.gz course bundle sent as the
+// request body. Nothing is written unless the whole bundle is valid.
+func (h *Handler) ImportCourse(w http.ResponseWriter, r *http.Request, user database.User) {
+	course, err := bundle.ReadArchive(http.MaxBytesReader(w, r.Body, bundle.MaxBundleSize))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if problems := validateBundle(course); len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Invalid course bundle",
+			"errors": problems,
+		})
+		return
+	}
+
+	// Without a status the course is reviewed and published by hand, while
+	// its lessons show up as soon as it does. validateBundle checked these.
+	courseStatus, coursePublishAt, _ := resolveStatus(course.Status, StatusDraft, course.PublishAt)
+
+	var created database.Course
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		created, err = q.CreateCourse(r.Context(), database.CreateCourseParams{
+			Title:       course.Title,
+			Description: course.Description,
+			Slug:        course.Slug,
+			Status:      courseStatus,
+			PublishAt:   coursePublishAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(r.Context(), q, revisionCourse, created.ID, actionCreated, user, created); err != nil {
+			return err
+		}
+
+		for _, l := range course.Lessons {
+			status, publishAt, _ := resolveStatus(l.Status, StatusPublished, l.PublishAt)
+			lesson, err := q.CreateLesson(r.Context(), database.CreateLessonParams{
+				CourseID:  created.ID,
+				Title:     l.Title,
+				Content:   l.Content,
+				Position:  l.Position,
+				Slug:      l.Slug,
+				Status:    status,
+				PublishAt: publishAt,
+			})
+			if err != nil {
+				return err
+			}
+			if err := recordRevision(r.Context(), q, revisionLesson, lesson.ID, actionCreated, user, lesson); err != nil {
+				return err
+			}
+
+			for _, t := range l.Tasks {
+				task, err := q.CreateTask(r.Context(), database.CreateTaskParams{
+					LessonID:    lesson.ID,
+					Description: t.Description,
+					Position:    t.Position,
+				})
+				if err != nil {
+					return err
+				}
+				if err := recordRevision(r.Context(), q, revisionTask, task.ID, actionCreated, user, task); err != nil {
+					return err
+				}
+				for _, s := range t.Steps {
+					step, err := q.CreateTaskStep(r.Context(), database.CreateTaskStepParams{
+						TaskID:         task.ID,
+						Position:       s.Position,
+						Command:        s.Command,
+						ExpectedOutput: s.ExpectedOutput,
+						MatchMode:      string(matchModeOrDefault(s.MatchMode)),
+						Tolerance:      s.Tolerance,
+					})
+					if err != nil {
+						return err
+					}
+					if err := recordRevision(r.Context(), q, revisionStep, step.ID, actionCreated, user, step); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "` + conflictMessage(err) + `"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(created)
+}
+
+// validateBundle applies the same rules as the admin endpoints to every
+// lesson and task in the bundle.
+func validateBundle(course *bundle.Course) []string {
+	var problems []string
+
+	if !slugPattern.MatchString(course.Slug) {
+		problems = append(problems, "slug may only contain lowercase letters, digits and single dashes")
+	}
+	if _, _, ok := resolveStatus(course.Status, StatusDraft, course.PublishAt); !ok {
+		problems = append(problems, invalidStatus)
+	}
+
+	positions := make(map[int32]bool, len(course.Lessons))
+	slugs := make(map[string]bool, len(course.Lessons))
+	for i, l := range course.Lessons {
+		if !slugPattern.MatchString(l.Slug) {
+			problems = append(problems, fmt.Sprintf("lessons[%d]: slug may only contain lowercase letters, digits and single dashes", i))
+		} else if slugs[l.Slug] {
+			problems = append(problems, fmt.Sprintf("lessons[%d]: slug %q is used more than once", i, l.Slug))
+		}
+		slugs[l.Slug] = true
+
+		if strings.TrimSpace(l.Title) == "" {
+			problems = append(problems, fmt.Sprintf("lessons[%d]: title must not be empty", i))
+		}
+		if _, _, ok := resolveStatus(l.Status, StatusPublished, l.PublishAt); !ok {
+			problems = append(problems, fmt.Sprintf("lessons[%d]: %s", i, invalidStatus))
+		}
+		if l.Position < 1 {
+			problems = append(problems, fmt.Sprintf("lessons[%d]: position must be 1 or greater", i))
+		} else if positions[l.Position] {
+			problems = append(problems, fmt.Sprintf("lessons[%d]: position %d is used more than once", i, l.Position))
+		}
+		positions[l.Position] = true
+
+		taskPositions := make(map[int32]bool, len(l.Tasks))
+		for j, t := range l.Tasks {
+			if t.Position < 1 {
+				problems = append(problems, fmt.Sprintf("lessons[%d].tasks[%d]: position must be 1 or greater", i, j))
+			} else if taskPositions[t.Position] {
+				problems = append(problems, fmt.Sprintf("lessons[%d].tasks[%d]: position %d is used more than once", i, j, t.Position))
+			}
+			taskPositions[t.Position] = true
+
+			req := TaskRequest{Description: t.Description}
+			for _, s := range t.Steps {
+				req.Steps = append(req.Steps, StepRequest{
+					Command:        s.Command,
+					ExpectedOutput: s.ExpectedOutput,
+					Position:       s.Position,
+					MatchMode:      s.MatchMode,
+					Tolerance:      s.Tolerance,
+				})
+			}
+			for _, problem := range req.validate() {
+				problems = append(problems, fmt.Sprintf("lessons[%d].tasks[%d]: %s", i, j, problem))
+			}
+		}
+	}
+
+	return problems
+}
+
+// ExportCourse sends an existing course back as a .tar.gz course bundle.
+func (h *Handler) ExportCourse(w http.ResponseWriter, r *http.Request, user database.User) {
+	courseID, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	course, err := h.DB.GetCourse(r.Context(), courseID)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Course not found"}`))
+		return
+	}
+
+	out, err := h.courseBundle(r, course)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// Build the archive first so a failure can still become a 500
+	var buf bytes.Buffer
+	if err := bundle.WriteArchive(&buf, out); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar.gz"`, course.Slug))
+	w.WriteHeader(200)
+	w.Write(buf.Bytes())
+}
+
+func (h *Handler) courseBundle(r *http.Request, course database.Course) (*bundle.Course, error) {
+	lessons, err := h.DB.GetLessonsByCourseID(r.Context(), course.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bundle.Course{
+		Title:       course.Title,
+		Slug:        course.Slug,
+		Description: course.Description,
+		Status:      course.Status,
+		PublishAt:   course.PublishAt,
+		Lessons:     make([]bundle.Lesson, 0, len(lessons)),
+	}
+
+	for _, l := range lessons {
+		lesson := bundle.Lesson{
+			Title:     l.Title,
+			Slug:      l.Slug,
+			Position:  l.Position,
+			Status:    l.Status,
+			PublishAt: l.PublishAt,
+			Content:   l.Content,
+		}
+
+		tasks, err := h.DB.GetTasksByLessonID(r.Context(), l.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, task := range tasks {
+			steps, err := h.DB.GetStepsByTaskID(r.Context(), task.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			t := bundle.Task{Position: task.Position, Description: task.Description}
+			for _, s := range steps {
+				t.Steps = append(t.Steps, bundle.Step{
+					Position:       s.Position,
+					Command:        s.Command,
+					ExpectedOutput: s.ExpectedOutput,
+					MatchMode:      s.MatchMode,
+					Tolerance:      s.Tolerance,
+				})
+			}
+			lesson.Tasks = append(lesson.Tasks, t)
+		}
+
+		out.Lessons = append(out.Lessons, lesson)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package content
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// CourseDetail is the full content tree of a course as admins see it,
+// including the IDs needed to edit any part of it.
+type CourseDetail struct {
+	database.Course
+	Lessons []LessonDetail `json:"lessons"`
+}
+
+type LessonDetail struct {
+	database.Lesson
+	Tasks []TaskDetail `json:"tasks"` // In order, empty for reading-only lessons
+}
+
+type TaskDetail struct {
+	database.Task
+	Steps []database.TaskStep `json:"steps"`
+}
+
+func (h *Handler) GetCourseDetail(w http.ResponseWriter, r *http.Request, user database.User) {
+	courseID, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	course, err := h.DB.GetCourse(r.Context(), courseID)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Course not found"}`))
+		return
+	}
+
+	lessons, err := h.DB.GetLessonsByCourseID(r.Context(), course.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	detail := CourseDetail{
+		Course:  course,
+		Lessons: make([]LessonDetail, 0, len(lessons)),
+	}
+	for _, l := range lessons {
+		lesson := LessonDetail{Lesson: l}
+
+		tasks, err := h.DB.GetTasksByLessonID(r.Context(), l.ID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		lesson.Tasks = make([]TaskDetail, 0, len(tasks))
+		for _, task := range tasks {
+			steps, err := h.DB.GetStepsByTaskID(r.Context(), task.ID)
+			if err != nil {
+				w.WriteHeader(500)
+				return
+			}
+			if steps == nil {
+				steps = []database.TaskStep{}
+			}
+			lesson.Tasks = append(lesson.Tasks, TaskDetail{Task: task, Steps: steps})
+		}
+
+		detail.Lessons = append(detail.Lessons, lesson)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
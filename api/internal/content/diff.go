@@ -0,0 +1,86 @@
+package content
+
+import "strings"
+
+// maxDiffCells caps the table diffLines builds. Texts whose changed parts
+// are bigger than that are shown as removed and added wholesale.
+const maxDiffCells = 1 << 22
+
+// diffLines compares two texts line by line. Every line is returned with a
+// prefix like in a unified diff: " " when kept, "-" when removed and "+"
+// when added.
+func diffLines(a, b string) []string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// Edits are usually small, so only the middle needs the full comparison
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]string, 0, len(x)+len(y))
+	for _, line := range x[:prefix] {
+		lines = append(lines, " "+line)
+	}
+	lines = append(lines, diffMiddle(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	for _, line := range x[len(x)-suffix:] {
+		lines = append(lines, " "+line)
+	}
+	return lines
+}
+
+// diffMiddle walks the longest common subsequence of x and y.
+func diffMiddle(x, y []string) []string {
+	var lines []string
+	if len(x)*len(y) > maxDiffCells {
+		for _, line := range x {
+			lines = append(lines, "-"+line)
+		}
+		for _, line := range y {
+			lines = append(lines, "+"+line)
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, " "+x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+x[i])
+			i++
+		default:
+			lines = append(lines, "+"+y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, "-"+x[i])
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, "+"+y[j])
+	}
+	return lines
+}
@@ -0,0 +1,31 @@
+package content
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// isUniqueViolation reports whether err is a Postgres unique constraint error.
+// Positions are kept unique by exclusion constraints, which skip rows in the
+// trash, so their errors count too.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "23505" || pgErr.Code == "23P01")
+}
+
+// isCheckViolation reports whether err is a Postgres CHECK constraint error.
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
+
+// conflictMessage describes which unique constraint a write ran into.
+func conflictMessage(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && strings.Contains(pgErr.ConstraintName, "slug") {
+		return "Slug is already taken"
+	}
+	return "Position is already taken"
+}
@@ -1,32 +1,56 @@
 package content
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/matcher"
+	"github.com/Tikkaaa3/t-learn/api/internal/runner"
 	"github.com/google/uuid"
 )
 
+// CLIResponse is a lesson with its tasks in order. TaskID, TaskDescription
+// and Steps repeat the first task for clients from before a lesson could have
+// more than one, they are empty when the lesson is only meant to be read.
 type CLIResponse struct {
-	LessonID        string `json:"lesson_id"`
-	LessonTitle     string `json:"lesson_title"`
-	LessonContent   string `json:"lesson_content"`
-	TaskID          string `json:"task_id"`
-	TaskDescription string `json:"task_description"`
-	Steps           []Step `json:"steps"`
+	LessonID        string         `json:"lesson_id"`
+	LessonTitle     string         `json:"lesson_title"`
+	LessonContent   string         `json:"lesson_content"`
+	TaskID          string         `json:"task_id"`
+	TaskDescription string         `json:"task_description"`
+	Steps           []Step         `json:"steps"`
+	Tasks           []TaskResponse `json:"tasks"`
+}
+
+type TaskResponse struct {
+	ID          string `json:"id"`
+	Position    int32  `json:"position"`
+	Description string `json:"description"`
+	Steps       []Step `json:"steps"`
 }
 
 type Step struct {
-	Position       int32  `json:"position"`
-	Command        string `json:"command"`
-	ExpectedOutput string `json:"expected_output"`
+	Position       int32   `json:"position"`
+	Command        string  `json:"command"`
+	ExpectedOutput string  `json:"expected_output"`
+	MatchMode      string  `json:"match_mode"`          // See the matcher package
+	Tolerance      float64 `json:"tolerance,omitempty"` // Only used by "numeric"
 }
 
 type Handler struct {
-	DB *database.Queries
+	DB             *database.Queries
+	Conn           *sql.DB        // Needed to open transactions for multi-row writes
+	Runner         *runner.Runner // Optional, nil disables POST /tasks/{task_id}/run
+	TrashRetention int32          // Days deleted content is kept before it is purged
 }
 
+// GetCourses lists the published courses, see ListCourses for all of them.
 func (h *Handler) GetCourses(w http.ResponseWriter, r *http.Request) {
 	courses, err := h.DB.GetCourses(r.Context())
 	if err != nil {
@@ -34,11 +58,25 @@ func (h *Handler) GetCourses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now := time.Now()
+	published := []database.Course{}
+	for _, c := range courses {
+		if listed(c.Status, c.PublishAt, now) {
+			published = append(published, c)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(courses)
+	json.NewEncoder(w).Encode(published)
 }
 
 func (h *Handler) GetLessons(w http.ResponseWriter, r *http.Request, user database.User) {
+	h.writeLessons(w, r, user, false)
+}
+
+// writeLessons lists the lessons of a course as students see them. A preview
+// also includes draft and scheduled lessons, and works for draft courses.
+func (h *Handler) writeLessons(w http.ResponseWriter, r *http.Request, user database.User, preview bool) {
 	courseIDStr := r.PathValue("course_id")
 	courseID, err := uuid.Parse(courseIDStr)
 	if err != nil {
@@ -46,6 +84,14 @@ func (h *Handler) GetLessons(w http.ResponseWriter, r *http.Request, user databa
 		return
 	}
 
+	now := time.Now()
+	course, err := h.DB.GetCourse(r.Context(), courseID)
+	if err != nil || (!preview && !reachable(course.Status, course.PublishAt, now)) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Course not found"}`))
+		return
+	}
+
 	lessons, err := h.DB.GetLessonsWithStatus(r.Context(), database.GetLessonsWithStatusParams{
 		CourseID: courseID,
 		UserID:   user.ID, // <--- We pass the logged-in user's ID here
@@ -59,16 +105,25 @@ func (h *Handler) GetLessons(w http.ResponseWriter, r *http.Request, user databa
 	type LessonResponse struct {
 		ID        uuid.UUID `json:"id"`
 		Title     string    `json:"title"`
+		Tasks     int64     `json:"tasks"`     // 0 for lessons that are only read
 		Completed bool      `json:"completed"` // <--- New JSON field
 	}
 
-	response := make([]LessonResponse, len(lessons))
-	for i, l := range lessons {
-		response[i] = LessonResponse{
+	response := make([]LessonResponse, 0, len(lessons))
+	for _, l := range lessons {
+		visible := listed(l.Status, l.PublishAt, now)
+		if preview {
+			visible = l.Status != StatusArchived
+		}
+		if !visible {
+			continue
+		}
+		response = append(response, LessonResponse{
 			ID:        l.ID,
 			Title:     l.Title,
+			Tasks:     l.TaskCount,
 			Completed: l.IsCompleted, // Map the boolean from SQL
-		}
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -76,6 +131,12 @@ func (h *Handler) GetLessons(w http.ResponseWriter, r *http.Request, user databa
 }
 
 func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
+	h.writeTask(w, r, false)
+}
+
+// writeTask shows a lesson and its tasks as students see them. Without preview
+// the lesson must be reachable by students.
+func (h *Handler) writeTask(w http.ResponseWriter, r *http.Request, preview bool) {
 	lessonIDStr := r.PathValue("lesson_id")
 	lessonID, err := uuid.Parse(lessonIDStr)
 	if err != nil {
@@ -83,6 +144,19 @@ func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !preview {
+		ok, err := h.lessonReachable(r.Context(), lessonID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte(`{"error": "Lesson not found"}`))
+			return
+		}
+	}
+
 	// Fetch the Lesson (Title & Content)
 	lesson, err := h.DB.GetLesson(r.Context(), lessonID)
 	if err != nil {
@@ -91,73 +165,109 @@ func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch the Task
-	task, err := h.DB.GetTaskByLessonID(r.Context(), lessonID)
-	if err != nil {
-		// It's okay if a lesson has no task (maybe it's just reading)
-		// But for now, we return 404
-		w.WriteHeader(404)
-		w.Write([]byte(`{"error": "Task not found"}`))
-		return
-	}
-
-	// Fetch the Steps
-	steps, err := h.DB.GetStepsByTaskID(r.Context(), task.ID)
+	// Fetch the Tasks, a lesson without any is just reading
+	tasks, err := h.DB.GetTasksByLessonID(r.Context(), lessonID)
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
 
-	// Build the Response
-	jsonSteps := []Step{}
-	for _, s := range steps {
-		jsonSteps = append(jsonSteps, Step{
-			Position:       s.Position,
-			Command:        s.Command,
-			ExpectedOutput: s.ExpectedOutput,
+	jsonTasks := []TaskResponse{}
+	for _, t := range tasks {
+		steps, err := h.DB.GetStepsByTaskID(r.Context(), t.ID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		jsonSteps := []Step{}
+		for _, s := range steps {
+			jsonSteps = append(jsonSteps, Step{
+				Position:       s.Position,
+				Command:        s.Command,
+				ExpectedOutput: s.ExpectedOutput,
+				MatchMode:      s.MatchMode,
+				Tolerance:      s.Tolerance,
+			})
+		}
+		jsonTasks = append(jsonTasks, TaskResponse{
+			ID:          t.ID.String(),
+			Position:    t.Position,
+			Description: t.Description, // "Create a hello.go file..."
+			Steps:       jsonSteps,
 		})
 	}
 
+	// Build the Response
 	response := CLIResponse{
-		LessonID:        lesson.ID.String(),
-		LessonTitle:     lesson.Title,
-		LessonContent:   lesson.Content, // The Markdown content
-		TaskID:          task.ID.String(),
-		TaskDescription: task.Description, // "Create a hello.go file..."
-		Steps:           jsonSteps,
+		LessonID:      lesson.ID.String(),
+		LessonTitle:   lesson.Title,
+		LessonContent: lesson.Content, // The Markdown content
+		Steps:         []Step{},
+		Tasks:         jsonTasks,
+	}
+	if len(jsonTasks) > 0 {
+		response.TaskID = jsonTasks[0].ID
+		response.TaskDescription = jsonTasks[0].Description
+		response.Steps = jsonTasks[0].Steps
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request, user database.User) {
-	taskIDStr := r.PathValue("task_id")
-	taskID, err := uuid.Parse(taskIDStr)
+// MarkLessonRead completes a lesson without tasks for the user. A lesson with
+// tasks is completed by passing all of them instead.
+func (h *Handler) MarkLessonRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	lessonID, err := uuid.Parse(r.PathValue("lesson_id"))
 	if err != nil {
 		w.WriteHeader(400)
 		return
 	}
 
-	err = h.DB.CompleteTask(r.Context(), database.CompleteTaskParams{
-		UserID: user.ID,
-		TaskID: taskID,
-	})
+	ok, err := h.lessonReachable(r.Context(), lessonID)
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Lesson not found"}`))
+		return
+	}
 
-	w.WriteHeader(200)
-	w.Write([]byte(`{"status":"success"}`))
+	tasks, err := h.DB.GetTasksByLessonID(r.Context(), lessonID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if len(tasks) > 0 {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Lesson has tasks, pass them to complete it"}`))
+		return
+	}
+
+	// Marking a lesson read twice is fine
+	err = h.DB.MarkLessonRead(r.Context(), database.MarkLessonReadParams{
+		UserID:   user.ID,
+		LessonID: lessonID,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
 }
 
 // Admin
 
 func (h *Handler) CreateCourse(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		Slug        string     `json:"slug"`   // Derived from the title if empty
+		Status      string     `json:"status"` // Draft if empty
+		PublishAt   *time.Time `json:"publish_at"`
 	}
 
 	var params parameters
@@ -166,10 +276,39 @@ func (h *Handler) CreateCourse(w http.ResponseWriter, r *http.Request, user data
 		return
 	}
 
-	course, err := h.DB.CreateCourse(r.Context(), database.CreateCourseParams{
-		Title:       params.Title,
-		Description: params.Description,
+	status, publishAt, ok := resolveStatus(params.Status, StatusDraft, params.PublishAt)
+	if !ok {
+		writeInvalidStatus(w)
+		return
+	}
+
+	slug, ok := resolveSlug(params.Slug, params.Title)
+	if !ok {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Slug may only contain lowercase letters, digits and single dashes"}`))
+		return
+	}
+
+	var course database.Course
+	err := h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		course, err = q.CreateCourse(r.Context(), database.CreateCourseParams{
+			Title:       params.Title,
+			Description: params.Description,
+			Slug:        slug,
+			Status:      status,
+			PublishAt:   publishAt,
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionCourse, course.ID, actionCreated, user, course)
 	})
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "` + conflictMessage(err) + `"}`))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(500)
 		return
@@ -187,10 +326,15 @@ func (h *Handler) CreateLesson(w http.ResponseWriter, r *http.Request, user data
 		return
 	}
 
+	// Without a position or after_lesson_id the lesson is added at the end
 	type parameters struct {
-		Title    string `json:"title"`
-		Content  string `json:"content"`
-		Position int32  `json:"position"`
+		Title         string     `json:"title"`
+		Content       string     `json:"content"`
+		Position      int32      `json:"position"`
+		AfterLessonID *uuid.UUID `json:"after_lesson_id"`
+		Slug          string     `json:"slug"`   // Derived from the title if empty
+		Status        string     `json:"status"` // Published if empty
+		PublishAt     *time.Time `json:"publish_at"`
 	}
 
 	var params parameters
@@ -198,13 +342,84 @@ func (h *Handler) CreateLesson(w http.ResponseWriter, r *http.Request, user data
 		w.WriteHeader(400)
 		return
 	}
+	if params.Position != 0 && params.AfterLessonID != nil {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Send either position or after_lesson_id, not both"}`))
+		return
+	}
 
-	lesson, err := h.DB.CreateLesson(r.Context(), database.CreateLessonParams{
-		CourseID: courseID,
-		Title:    params.Title,
-		Content:  params.Content,
-		Position: params.Position,
+	status, publishAt, ok := resolveStatus(params.Status, StatusPublished, params.PublishAt)
+	if !ok {
+		writeInvalidStatus(w)
+		return
+	}
+
+	slug, ok := resolveSlug(params.Slug, params.Title)
+	if !ok {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Slug may only contain lowercase letters, digits and single dashes"}`))
+		return
+	}
+
+	// Also keeps lessons out of courses in the trash
+	if _, err := h.DB.GetCourse(r.Context(), courseID); err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Course not found"}`))
+		return
+	}
+
+	var lesson database.Lesson
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		position := params.Position
+		switch {
+		case params.AfterLessonID != nil:
+			after, err := q.GetLesson(r.Context(), *params.AfterLessonID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && after.CourseID != courseID) {
+				return errAfterNotFound
+			}
+			if err != nil {
+				return err
+			}
+			err = q.ShiftLessonPositions(r.Context(), database.ShiftLessonPositionsParams{
+				CourseID: courseID,
+				Position: after.Position,
+			})
+			if err != nil {
+				return err
+			}
+			position = after.Position + 1
+		case position == 0:
+			var err error
+			if position, err = q.GetNextLessonPosition(r.Context(), courseID); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		lesson, err = q.CreateLesson(r.Context(), database.CreateLessonParams{
+			CourseID:  courseID,
+			Title:     params.Title,
+			Content:   params.Content,
+			Position:  position,
+			Slug:      slug,
+			Status:    status,
+			PublishAt: publishAt,
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionLesson, lesson.ID, actionCreated, user, lesson)
 	})
+	if errors.Is(err, errAfterNotFound) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "after_lesson_id is not a lesson of this course"}`))
+		return
+	}
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "` + conflictMessage(err) + `"}`))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(500)
 		return
@@ -214,6 +429,79 @@ func (h *Handler) CreateLesson(w http.ResponseWriter, r *http.Request, user data
 	json.NewEncoder(w).Encode(lesson)
 }
 
+type StepRequest struct {
+	Command        string  `json:"command"`
+	ExpectedOutput string  `json:"expected_output"`
+	Position       int32   `json:"position"`
+	MatchMode      string  `json:"match_mode"`
+	Tolerance      float64 `json:"tolerance"`
+}
+
+type TaskRequest struct {
+	Description string        `json:"description"`
+	Steps       []StepRequest `json:"steps"`
+}
+
+func matchModeOrDefault(mode string) matcher.Mode {
+	if mode == "" {
+		return matcher.Default
+	}
+	return matcher.Mode(mode)
+}
+
+// validate collects every problem with the request instead of stopping at the
+// first one, so the admin can fix them all in one go.
+func (req TaskRequest) validate() []string {
+	var problems []string
+
+	if strings.TrimSpace(req.Description) == "" {
+		problems = append(problems, "description must not be empty")
+	}
+	if len(req.Steps) == 0 {
+		problems = append(problems, "a task needs at least one step")
+	}
+
+	seen := make(map[int32]bool, len(req.Steps))
+	for i, step := range req.Steps {
+		if step.Position >= 1 && seen[step.Position] {
+			problems = append(problems, fmt.Sprintf("steps[%d]: position %d is used more than once", i, step.Position))
+		}
+		seen[step.Position] = true
+
+		for _, problem := range step.validate() {
+			problems = append(problems, fmt.Sprintf("steps[%d]: %s", i, problem))
+		}
+	}
+
+	return problems
+}
+
+func (step StepRequest) validate() []string {
+	var problems []string
+
+	if step.Position < 1 {
+		problems = append(problems, "position must be 1 or greater")
+	}
+	return append(problems, step.validateBody()...)
+}
+
+// validateBody checks everything but the position, for steps that get one
+// assigned.
+func (step StepRequest) validateBody() []string {
+	var problems []string
+
+	if strings.TrimSpace(step.Command) == "" {
+		problems = append(problems, "command must not be empty")
+	}
+	if _, err := matcher.New(matcher.Mode(step.MatchMode), step.ExpectedOutput, step.Tolerance); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	return problems
+}
+
+// CreateTask adds a task with its steps to a lesson. Without a position or
+// after_task_id the task is added after the lesson's other tasks.
 func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request, user database.User) {
 	lessonIDStr := r.PathValue("lesson_id")
 	lessonID, err := uuid.Parse(lessonIDStr)
@@ -222,55 +510,254 @@ func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request, user databa
 		return
 	}
 
-	type StepRequest struct {
-		Command        string `json:"command"`
-		ExpectedOutput string `json:"expected_output"`
-		Position       int32  `json:"position"`
-	}
-	type TaskRequest struct {
-		Description string        `json:"description"`
-		Steps       []StepRequest `json:"steps"`
+	type parameters struct {
+		TaskRequest
+		Position    int32      `json:"position"`
+		AfterTaskID *uuid.UUID `json:"after_task_id"`
 	}
 
-	var req TaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		w.WriteHeader(400)
 		return
 	}
+	req := params.TaskRequest
+
+	problems := req.validate()
+	switch {
+	case params.Position != 0 && params.AfterTaskID != nil:
+		problems = append([]string{"send either position or after_task_id, not both"}, problems...)
+	case params.Position < 0:
+		problems = append([]string{"position must be 1 or greater"}, problems...)
+	}
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Invalid task",
+			"errors": problems,
+		})
+		return
+	}
+
+	if _, err := h.DB.GetLesson(r.Context(), lessonID); err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Lesson not found"}`))
+		return
+	}
+
+	// The task and all of its steps are written together or not at all
+	var task database.Task
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		position := params.Position
+		switch {
+		case params.AfterTaskID != nil:
+			after, err := q.GetTask(r.Context(), *params.AfterTaskID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && after.LessonID != lessonID) {
+				return errAfterNotFound
+			}
+			if err != nil {
+				return err
+			}
+			err = q.ShiftTaskPositions(r.Context(), database.ShiftTaskPositionsParams{
+				LessonID: lessonID,
+				Position: after.Position,
+			})
+			if err != nil {
+				return err
+			}
+			position = after.Position + 1
+		case position == 0:
+			var err error
+			if position, err = q.GetNextTaskPosition(r.Context(), lessonID); err != nil {
+				return err
+			}
+		}
 
-	task, err := h.DB.CreateTask(r.Context(), database.CreateTaskParams{
-		LessonID:    lessonID,
-		Description: req.Description,
+		var err error
+		task, err = q.CreateTask(r.Context(), database.CreateTaskParams{
+			LessonID:    lessonID,
+			Description: req.Description,
+			Position:    position,
+		})
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(r.Context(), q, revisionTask, task.ID, actionCreated, user, task); err != nil {
+			return err
+		}
+
+		for _, step := range req.Steps {
+			created, err := q.CreateTaskStep(r.Context(), database.CreateTaskStepParams{
+				TaskID:         task.ID,
+				Position:       step.Position,
+				Command:        step.Command,
+				ExpectedOutput: step.ExpectedOutput,
+				MatchMode:      string(matchModeOrDefault(step.MatchMode)),
+				Tolerance:      step.Tolerance,
+			})
+			if err != nil {
+				return err
+			}
+			if err := recordRevision(r.Context(), q, revisionStep, created.ID, actionCreated, user, created); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if errors.Is(err, errAfterNotFound) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "after_task_id is not a task of this lesson"}`))
+		return
+	}
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Position is already taken"}`))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
 
-	for _, step := range req.Steps {
-		_, err := h.DB.CreateTaskStep(r.Context(), database.CreateTaskStepParams{
-			TaskID:         task.ID,
-			Position:       step.Position,
-			Command:        step.Command,
-			ExpectedOutput: step.ExpectedOutput,
+	w.WriteHeader(201)
+	w.Write([]byte(`{"status":"created", "task_id":"` + task.ID.String() + `"}`))
+}
+
+// CreateTaskStep adds a single step to an existing task.
+func (h *Handler) CreateTaskStep(w http.ResponseWriter, r *http.Request, user database.User) {
+	taskID, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	// Without a position or after_step_id the step is added at the end
+	type parameters struct {
+		StepRequest
+		AfterStepID *uuid.UUID `json:"after_step_id"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	req := params.StepRequest
+
+	var problems []string
+	switch {
+	case req.Position != 0 && params.AfterStepID != nil:
+		problems = append([]string{"send either position or after_step_id, not both"}, req.validateBody()...)
+	case req.Position == 0:
+		problems = req.validateBody()
+	default:
+		problems = req.validate()
+	}
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Invalid step",
+			"errors": problems,
+		})
+		return
+	}
+
+	if _, err := h.DB.GetTask(r.Context(), taskID); err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+
+	var step database.TaskStep
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		position := req.Position
+		switch {
+		case params.AfterStepID != nil:
+			after, err := q.GetTaskStep(r.Context(), *params.AfterStepID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && after.TaskID != taskID) {
+				return errAfterNotFound
+			}
+			if err != nil {
+				return err
+			}
+			err = q.ShiftTaskStepPositions(r.Context(), database.ShiftTaskStepPositionsParams{
+				TaskID:   taskID,
+				Position: after.Position,
+			})
+			if err != nil {
+				return err
+			}
+			position = after.Position + 1
+		case position == 0:
+			var err error
+			if position, err = q.GetNextTaskStepPosition(r.Context(), taskID); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		step, err = q.CreateTaskStep(r.Context(), database.CreateTaskStepParams{
+			TaskID:         taskID,
+			Position:       position,
+			Command:        req.Command,
+			ExpectedOutput: req.ExpectedOutput,
+			MatchMode:      string(matchModeOrDefault(req.MatchMode)),
+			Tolerance:      req.Tolerance,
 		})
 		if err != nil {
-			w.WriteHeader(500)
-			return
+			return err
 		}
+		return recordRevision(r.Context(), q, revisionStep, step.ID, actionCreated, user, step)
+	})
+	if errors.Is(err, errAfterNotFound) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "after_step_id is not a step of this task"}`))
+		return
+	}
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "Position is already taken"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(201)
-	w.Write([]byte(`{"status":"created", "task_id":"` + task.ID.String() + `"}`))
+	json.NewEncoder(w).Encode(step)
 }
 
+// The delete handlers move content to the trash, see trash.go, and record
+// the trashed row as a revision. Deleting something that is not live, or
+// already in the trash, is a 404.
+
+// errLastStep keeps a task from losing its last step, a task without steps
+// could never be passed.
+var errLastStep = errors.New("the last step of a task can't be deleted")
+
 func (h *Handler) DeleteCourse(w http.ResponseWriter, r *http.Request, user database.User) {
 	id, err := uuid.Parse(r.PathValue("course_id"))
 	if err != nil {
 		w.WriteHeader(400)
 		return
 	}
-	if err := h.DB.DeleteCourse(r.Context(), id); err != nil {
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		course, err := q.TrashCourse(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionCourse, course.ID, actionDeleted, user, course)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Course not found"}`))
+		return
+	}
+	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
@@ -283,7 +770,19 @@ func (h *Handler) DeleteLesson(w http.ResponseWriter, r *http.Request, user data
 		w.WriteHeader(400)
 		return
 	}
-	if err := h.DB.DeleteLesson(r.Context(), id); err != nil {
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		lesson, err := q.TrashLesson(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionLesson, lesson.ID, actionDeleted, user, lesson)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Lesson not found"}`))
+		return
+	}
+	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
@@ -296,7 +795,68 @@ func (h *Handler) DeleteTask(w http.ResponseWriter, r *http.Request, user databa
 		w.WriteHeader(400)
 		return
 	}
-	if err := h.DB.DeleteTask(r.Context(), id); err != nil {
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		task, err := q.TrashTask(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionTask, task.ID, actionDeleted, user, task)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// DeleteTaskStep refuses to delete the last live step of a task, delete the
+// task instead.
+func (h *Handler) DeleteTaskStep(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("step_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		step, err := q.GetTaskStep(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		// Two deletes at once must not each leave the other's step as the last
+		if err := q.LockTask(r.Context(), step.TaskID); err != nil {
+			return err
+		}
+		steps, err := q.GetStepsByTaskID(r.Context(), step.TaskID)
+		if err != nil {
+			return err
+		}
+		if len(steps) <= 1 {
+			return errLastStep
+		}
+
+		step, err = q.TrashTaskStep(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionStep, step.ID, actionDeleted, user, step)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Step not found"}`))
+		return
+	}
+	if errors.Is(err, errLastStep) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "This is the last step of the task, delete the task instead"}`))
+		return
+	}
+	if err != nil {
 		w.WriteHeader(500)
 		return
 	}
@@ -0,0 +1,287 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// Positions are managed here rather than by the client: a reorder rewrites
+// all of them to 1..n in one transaction and records a revision for each row
+// that moved, and creating with after_lesson_id, after_task_id or
+// after_step_id shifts the rows behind the new one out of the way.
+
+var (
+	errNotAPermutation = errors.New("the list must contain every item exactly once")
+	errAfterNotFound   = errors.New("the item to insert after does not exist here")
+)
+
+// samePermutation reports whether ids holds exactly the IDs in have.
+func samePermutation(ids, have []uuid.UUID) bool {
+	if len(ids) != len(have) {
+		return false
+	}
+	seen := make(map[uuid.UUID]bool, len(have))
+	for _, id := range have {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			return false // Unknown or listed twice
+		}
+		delete(seen, id)
+	}
+	return true
+}
+
+// ReorderLessons puts the lessons of a course in the order of lesson_ids,
+// which must list every lesson of the course.
+func (h *Handler) ReorderLessons(w http.ResponseWriter, r *http.Request, user database.User) {
+	courseID, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		LessonIDs []uuid.UUID `json:"lesson_ids"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if _, err := h.DB.GetCourse(r.Context(), courseID); err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Course not found"}`))
+		return
+	}
+
+	var lessons []database.Lesson
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		current, err := q.GetLessonsByCourseID(r.Context(), courseID)
+		if err != nil {
+			return err
+		}
+		have := make([]uuid.UUID, len(current))
+		positions := make(map[uuid.UUID]int32, len(current))
+		for i, l := range current {
+			have[i] = l.ID
+			positions[l.ID] = l.Position
+		}
+		if !samePermutation(params.LessonIDs, have) {
+			return errNotAPermutation
+		}
+
+		if err := q.DeferPositionConstraints(r.Context()); err != nil {
+			return err
+		}
+		for i, id := range params.LessonIDs {
+			_, err := q.SetLessonPosition(r.Context(), database.SetLessonPositionParams{
+				ID:       id,
+				CourseID: courseID,
+				Position: int32(i + 1),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		lessons, err = q.GetLessonsByCourseID(r.Context(), courseID)
+		if err != nil {
+			return err
+		}
+		for _, l := range lessons {
+			if l.Position == positions[l.ID] {
+				continue // Not moved
+			}
+			if err := recordRevision(r.Context(), q, revisionLesson, l.ID, actionReordered, user, l); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNotAPermutation) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "lesson_ids must list every lesson of the course exactly once"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lessons)
+}
+
+// ReorderTasks puts the tasks of a lesson in the order of task_ids, which
+// must list every task of the lesson.
+func (h *Handler) ReorderTasks(w http.ResponseWriter, r *http.Request, user database.User) {
+	lessonID, err := uuid.Parse(r.PathValue("lesson_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		TaskIDs []uuid.UUID `json:"task_ids"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if _, err := h.DB.GetLesson(r.Context(), lessonID); err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Lesson not found"}`))
+		return
+	}
+
+	var tasks []database.Task
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		current, err := q.GetTasksByLessonID(r.Context(), lessonID)
+		if err != nil {
+			return err
+		}
+		have := make([]uuid.UUID, len(current))
+		positions := make(map[uuid.UUID]int32, len(current))
+		for i, t := range current {
+			have[i] = t.ID
+			positions[t.ID] = t.Position
+		}
+		if !samePermutation(params.TaskIDs, have) {
+			return errNotAPermutation
+		}
+
+		if err := q.DeferPositionConstraints(r.Context()); err != nil {
+			return err
+		}
+		for i, id := range params.TaskIDs {
+			_, err := q.SetTaskPosition(r.Context(), database.SetTaskPositionParams{
+				ID:       id,
+				LessonID: lessonID,
+				Position: int32(i + 1),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		tasks, err = q.GetTasksByLessonID(r.Context(), lessonID)
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			if t.Position == positions[t.ID] {
+				continue // Not moved
+			}
+			if err := recordRevision(r.Context(), q, revisionTask, t.ID, actionReordered, user, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNotAPermutation) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "task_ids must list every task of the lesson exactly once"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// ReorderTaskSteps puts the steps of a task in the order of step_ids, which
+// must list every step of the task.
+func (h *Handler) ReorderTaskSteps(w http.ResponseWriter, r *http.Request, user database.User) {
+	taskID, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		StepIDs []uuid.UUID `json:"step_ids"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if _, err := h.DB.GetTask(r.Context(), taskID); err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+
+	var steps []database.TaskStep
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		current, err := q.GetStepsByTaskID(r.Context(), taskID)
+		if err != nil {
+			return err
+		}
+		have := make([]uuid.UUID, len(current))
+		positions := make(map[uuid.UUID]int32, len(current))
+		for i, s := range current {
+			have[i] = s.ID
+			positions[s.ID] = s.Position
+		}
+		if !samePermutation(params.StepIDs, have) {
+			return errNotAPermutation
+		}
+
+		if err := q.DeferPositionConstraints(r.Context()); err != nil {
+			return err
+		}
+		for i, id := range params.StepIDs {
+			_, err := q.SetTaskStepPosition(r.Context(), database.SetTaskStepPositionParams{
+				ID:       id,
+				TaskID:   taskID,
+				Position: int32(i + 1),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		steps, err = q.GetStepsByTaskID(r.Context(), taskID)
+		if err != nil {
+			return err
+		}
+		for _, s := range steps {
+			if s.Position == positions[s.ID] {
+				continue // Not moved
+			}
+			if err := recordRevision(r.Context(), q, revisionStep, s.ID, actionReordered, user, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNotAPermutation) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "step_ids must list every step of the task exactly once"}`))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(steps)
+}
@@ -0,0 +1,362 @@
+package content
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// Every create, update, status change, reorder, move to the trash, restore
+// from the trash and rollback of a course, lesson, task or step stores the
+// row as it looks afterwards, with the author and a version counting up per
+// row. A reorder records only the rows whose position changed. Rows deleted
+// or restored along with their parent get no revision of their own, and
+// neither do the shifts that make room for an inserted row.
+
+const (
+	revisionCourse = "course"
+	revisionLesson = "lesson"
+	revisionTask   = "task"
+	revisionStep   = "task_step"
+)
+
+const (
+	actionCreated    = "created"
+	actionUpdated    = "updated"
+	actionReordered  = "reordered"
+	actionDeleted    = "deleted"
+	actionRestored   = "restored"
+	actionRolledBack = "rolled_back"
+)
+
+// recordRevision stores row as the next revision of the row with the given
+// kind and ID. It must run in the transaction that wrote the row.
+func recordRevision(ctx context.Context, q *database.Queries, kind string, id uuid.UUID, action string, author database.User, row interface{}) error {
+	snapshot, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = q.CreateRevision(ctx, database.CreateRevisionParams{
+		EntityType: kind,
+		EntityID:   id,
+		Action:     action,
+		AuthorID:   uuid.NullUUID{UUID: author.ID, Valid: true},
+		Snapshot:   snapshot,
+	})
+	return err
+}
+
+// revisionEntity tells which row a revisions route is about. Steps are
+// checked first as their routes are the most specific.
+func revisionEntity(r *http.Request) (string, uuid.UUID, bool) {
+	for _, e := range []struct{ kind, param string }{
+		{revisionStep, "step_id"},
+		{revisionTask, "task_id"},
+		{revisionLesson, "lesson_id"},
+		{revisionCourse, "course_id"},
+	} {
+		if value := r.PathValue(e.param); value != "" {
+			id, err := uuid.Parse(value)
+			return e.kind, id, err == nil
+		}
+	}
+	return "", uuid.Nil, false
+}
+
+func parseVersion(s string) (int32, bool) {
+	v, err := strconv.ParseInt(s, 10, 32)
+	return int32(v), err == nil && v >= 1
+}
+
+// getRevision loads a revision and writes the error response if it can't.
+func (h *Handler) getRevision(w http.ResponseWriter, r *http.Request, kind string, id uuid.UUID, version int32) (database.ContentRevision, bool) {
+	rev, err := h.DB.GetRevision(r.Context(), database.GetRevisionParams{
+		EntityType: kind,
+		EntityID:   id,
+		Version:    version,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Revision not found"}`))
+		return rev, false
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return rev, false
+	}
+	return rev, true
+}
+
+// ListRevisions lists the revisions of a course, lesson, task or step,
+// newest first. Snapshots are left out, GetRevision has them.
+func (h *Handler) ListRevisions(w http.ResponseWriter, r *http.Request, user database.User) {
+	kind, id, ok := revisionEntity(r)
+	if !ok {
+		w.WriteHeader(400)
+		return
+	}
+
+	revisions, err := h.DB.GetRevisions(r.Context(), database.GetRevisionsParams{
+		EntityType: kind,
+		EntityID:   id,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if revisions == nil {
+		revisions = []database.GetRevisionsRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetRevision returns a single revision with its snapshot.
+func (h *Handler) GetRevision(w http.ResponseWriter, r *http.Request, user database.User) {
+	kind, id, ok := revisionEntity(r)
+	version, validVersion := parseVersion(r.PathValue("version"))
+	if !ok || !validVersion {
+		w.WriteHeader(400)
+		return
+	}
+
+	rev, ok := h.getRevision(w, r, kind, id, version)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// FieldChange is one field that differs between two revisions. Lines holds a
+// line by line diff when the field is multi-line text, such as lesson
+// content.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+	Lines []string    `json:"lines,omitempty"`
+}
+
+// ignoredFields change on every write and would only clutter a diff.
+var ignoredFields = map[string]bool{"id": true, "created_at": true, "updated_at": true}
+
+// sameValue reports whether a field has the same value in two snapshots.
+// Timestamps are compared as times: the snapshots the migration backfilled
+// write them without a zone, the API writes them in UTC with a Z.
+func sameValue(field string, a, b interface{}) bool {
+	if strings.HasSuffix(field, "_at") {
+		ta, okA := snapshotTime(a)
+		tb, okB := snapshotTime(b)
+		if okA && okB {
+			return ta.Equal(tb)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func snapshotTime(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// diffSnapshots lists the fields that differ between two snapshots of the
+// same row, sorted by name.
+func diffSnapshots(from, to json.RawMessage) ([]FieldChange, error) {
+	var a, b map[string]interface{}
+	if err := json.Unmarshal(from, &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &b); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(b))
+	for field := range a {
+		fields = append(fields, field)
+	}
+	for field := range b {
+		if _, ok := a[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if ignoredFields[field] || sameValue(field, a[field], b[field]) {
+			continue
+		}
+		change := FieldChange{Field: field, From: a[field], To: b[field]}
+		before, okBefore := a[field].(string)
+		after, okAfter := b[field].(string)
+		if okBefore && okAfter && (strings.Contains(before, "\n") || strings.Contains(after, "\n")) {
+			change.Lines = diffLines(before, after)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// DiffRevisions compares the revisions in the from and to query parameters.
+// Without to, from is compared with the latest revision.
+func (h *Handler) DiffRevisions(w http.ResponseWriter, r *http.Request, user database.User) {
+	kind, id, ok := revisionEntity(r)
+	if !ok {
+		w.WriteHeader(400)
+		return
+	}
+
+	from, ok := parseVersion(r.URL.Query().Get("from"))
+	if !ok {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "from must be a revision number"}`))
+		return
+	}
+
+	var to int32
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, ok = parseVersion(s); !ok {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error": "to must be a revision number"}`))
+			return
+		}
+	} else {
+		revisions, err := h.DB.GetRevisions(r.Context(), database.GetRevisionsParams{
+			EntityType: kind,
+			EntityID:   id,
+		})
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if len(revisions) > 0 {
+			to = revisions[0].Version
+		}
+	}
+
+	before, ok := h.getRevision(w, r, kind, id, from)
+	if !ok {
+		return
+	}
+	after, ok := h.getRevision(w, r, kind, id, to)
+	if !ok {
+		return
+	}
+
+	changes, err := diffSnapshots(before.Snapshot, after.Snapshot)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+	})
+}
+
+// editableFields are what a rollback takes from a snapshot, for any kind of
+// row. Snapshots are not decoded into the row types: the ones the migration
+// backfilled hold timestamps without a zone, which time.Time can't parse.
+type editableFields struct {
+	Title          *string  `json:"title"`
+	Description    *string  `json:"description"`
+	Slug           *string  `json:"slug"`
+	Content        *string  `json:"content"`
+	Command        *string  `json:"command"`
+	ExpectedOutput *string  `json:"expected_output"`
+	MatchMode      *string  `json:"match_mode"`
+	Tolerance      *float64 `json:"tolerance"`
+}
+
+// RollbackRevision restores the editable fields of a row from one of its
+// revisions. Position and status are left alone, they have their own
+// endpoints and restoring them could clash with rows that moved since. The
+// rollback is recorded as a new revision, so it can be undone too.
+func (h *Handler) RollbackRevision(w http.ResponseWriter, r *http.Request, user database.User) {
+	kind, id, ok := revisionEntity(r)
+	version, validVersion := parseVersion(r.PathValue("version"))
+	if !ok || !validVersion {
+		w.WriteHeader(400)
+		return
+	}
+
+	rev, ok := h.getRevision(w, r, kind, id, version)
+	if !ok {
+		return
+	}
+
+	var fields editableFields
+	if err := json.Unmarshal(rev.Snapshot, &fields); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	var row interface{}
+	err := h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		switch kind {
+		case revisionCourse:
+			row, err = q.UpdateCourse(r.Context(), database.UpdateCourseParams{
+				ID:          id,
+				Title:       nullString(fields.Title),
+				Description: nullString(fields.Description),
+				Slug:        nullString(fields.Slug),
+			})
+		case revisionLesson:
+			row, err = q.UpdateLesson(r.Context(), database.UpdateLessonParams{
+				ID:      id,
+				Title:   nullString(fields.Title),
+				Content: nullString(fields.Content),
+				Slug:    nullString(fields.Slug),
+			})
+		case revisionTask:
+			row, err = q.UpdateTask(r.Context(), database.UpdateTaskParams{
+				ID:          id,
+				Description: nullString(fields.Description),
+			})
+		case revisionStep:
+			row, err = q.UpdateTaskStep(r.Context(), database.UpdateTaskStepParams{
+				ID:             id,
+				Command:        nullString(fields.Command),
+				ExpectedOutput: nullString(fields.ExpectedOutput),
+				MatchMode:      nullString(fields.MatchMode),
+				Tolerance:      nullFloat64(fields.Tolerance),
+			})
+		}
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, kind, id, actionRolledBack, user, row)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(row)
+}
@@ -0,0 +1,198 @@
+package content
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// testDB migrates a fresh schema in the database at TEST_DATABASE_URL and
+// drops it when the test is done. Tests that need a database are skipped
+// without it.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	schema := fmt.Sprintf("test_%s", strings.ToLower(rand.Text()[:12]))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("pgx", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob("../../sql/schema/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(file), err)
+		}
+	}
+	return db
+}
+
+// backfilled is a course snapshot as to_jsonb writes it in the migration
+// that added revisions, api the same row as the API writes it.
+const (
+	backfilled = `{"id": "5d3c1f9e-7a51-4a0e-9d43-0f6f2b1c8a10", "created_at": "2024-05-01T10:00:00.123456", "updated_at": "2024-05-02T08:30:00", "title": "Python Basics", "description": "Learn Python.", "slug": "python-basics", "status": "published", "publish_at": "2024-05-03T00:00:00", "deleted_at": null}`
+	api        = `{"id": "5d3c1f9e-7a51-4a0e-9d43-0f6f2b1c8a10", "created_at": "2024-05-01T10:00:00.123456Z", "updated_at": "2024-06-01T12:00:00Z", "title": "Python 3 Basics", "description": "Learn Python.", "slug": "python-basics", "status": "published", "publish_at": "2024-05-03T00:00:00Z", "deleted_at": null}`
+)
+
+func TestDiffSnapshotsBackfilled(t *testing.T) {
+	changes, err := diffSnapshots(json.RawMessage(backfilled), json.RawMessage(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Field != "title" {
+		t.Errorf("got changes %+v, want only the title", changes)
+	}
+
+	// A timestamp that really changed still shows
+	moved := strings.Replace(api, `"publish_at": "2024-05-03T00:00:00Z"`, `"publish_at": "2024-05-04T00:00:00Z"`, 1)
+	changes, err = diffSnapshots(json.RawMessage(backfilled), json.RawMessage(moved))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Field != "publish_at" {
+		t.Errorf("got changes %+v, want publish_at and title", changes)
+	}
+}
+
+func TestEditableFieldsOfBackfilledSnapshot(t *testing.T) {
+	var fields editableFields
+	if err := json.Unmarshal([]byte(backfilled), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields.Title == nil || *fields.Title != "Python Basics" || fields.Slug == nil || *fields.Slug != "python-basics" {
+		t.Errorf("got %+v", fields)
+	}
+	if fields.Command != nil || fields.Tolerance != nil {
+		t.Error("fields of other kinds of rows set from a course")
+	}
+}
+
+func TestRollbackBackfilledRevision(t *testing.T) {
+	conn := testDB(t)
+	h := &Handler{DB: database.New(conn), Conn: conn}
+	ctx := context.Background()
+
+	user, err := h.DB.CreateUser(ctx, database.CreateUserParams{
+		Username:     "ada",
+		Email:        "ada@example.com",
+		PasswordHash: "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishAt := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	course, err := h.DB.CreateCourse(ctx, database.CreateCourseParams{
+		Title:       "Python Basics",
+		Description: "Learn Python.",
+		Slug:        "python-basics",
+		Status:      StatusPublished,
+		PublishAt:   &publishAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 1 the way the migration backfilled existing content
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO content_revisions (id, created_at, entity_type, entity_id, version, action, snapshot)
+		SELECT gen_random_uuid(), c.updated_at, 'course', c.id, 1, 'created', to_jsonb(c) FROM courses c WHERE c.id = $1`,
+		course.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title := "Python 3 Basics"
+	err = h.withTx(ctx, func(q *database.Queries) error {
+		updated, err := q.UpdateCourse(ctx, database.UpdateCourseParams{ID: course.ID, Title: nullString(&title)})
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, q, revisionCourse, course.ID, actionUpdated, user, updated)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/courses/"+course.ID.String()+"/revisions/1/rollback", nil)
+	req.SetPathValue("course_id", course.ID.String())
+	req.SetPathValue("version", "1")
+	rec := httptest.NewRecorder()
+	h.RollbackRevision(rec, req, user)
+	if rec.Code != 200 {
+		t.Fatalf("rollback: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	got, err := h.DB.GetCourse(ctx, course.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Python Basics" || got.Slug != "python-basics" || got.Description != "Learn Python." {
+		t.Errorf("rolled back to %+v", got)
+	}
+
+	// The rollback, version 3, matches the backfilled version 1
+	req = httptest.NewRequest(http.MethodGet, "/admin/courses/"+course.ID.String()+"/revisions/diff?from=1&to=3", nil)
+	req.SetPathValue("course_id", course.ID.String())
+	rec = httptest.NewRecorder()
+	h.DiffRevisions(rec, req, user)
+	var diff struct {
+		Changes []FieldChange `json:"changes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changes) != 0 {
+		t.Errorf("versions 1 and 3 differ in %+v", diff.Changes)
+	}
+}
@@ -0,0 +1,105 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/runner"
+	"github.com/google/uuid"
+)
+
+// RunTask executes the task's steps against the uploaded source files in the
+// server-side runner and grades the result like a normal submission. It is
+// only available when the server was started with a Runner.
+func (h *Handler) RunTask(w http.ResponseWriter, r *http.Request, user database.User) {
+	if h.Runner == nil {
+		w.WriteHeader(501)
+		w.Write([]byte(`{"error": "Server-side execution is disabled"}`))
+		return
+	}
+
+	taskID, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Files map[string]string `json:"files"` // File name -> source code
+	}
+
+	// JSON escaping can make the sources several times larger on the wire,
+	// the runner checks their real size once they are decoded
+	body := http.MaxBytesReader(w, r.Body, 6*int64(h.Runner.Limits.MaxInputBytes)+4096)
+
+	var params parameters
+	if err := json.NewDecoder(body).Decode(&params); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(413)
+			w.Write([]byte(`{"error": "Request body is too large"}`))
+			return
+		}
+		w.WriteHeader(400)
+		return
+	}
+
+	task, err := h.DB.GetTask(r.Context(), taskID)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+	if ok, err := h.lessonReachable(r.Context(), task.LessonID); err != nil || !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+
+	steps, err := h.DB.GetStepsByTaskID(r.Context(), task.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	runSteps := make([]runner.Step, len(steps))
+	for i, s := range steps {
+		runSteps[i] = runner.Step{Position: s.Position, Command: s.Command}
+	}
+
+	results, err := h.Runner.Run(r.Context(), params.Files, runSteps)
+	switch {
+	case errors.Is(err, runner.ErrInvalidFile), errors.Is(err, runner.ErrFilesTooLarge):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Error running task %s: %s", task.ID, err)
+		w.WriteHeader(500)
+		return
+	}
+
+	outputs := make([]StepOutput, len(results))
+	for i, res := range results {
+		outputs[i] = StepOutput{
+			Position: res.Position,
+			Stdout:   res.Stdout,
+			Stderr:   res.Stderr,
+			ExitCode: res.ExitCode,
+		}
+	}
+
+	response, err := h.recordSubmission(r.Context(), user, task, steps, outputs)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,21 @@
+package content
+
+import (
+	"regexp"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/bundle"
+)
+
+// Slugs give courses and lessons a stable, human readable identity that
+// survives edits to their titles. The seeder and course bundles match
+// existing content by slug.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// resolveSlug returns slug, or one derived from title if slug is empty. The
+// second result is false if an explicit slug is malformed.
+func resolveSlug(slug, title string) (string, bool) {
+	if slug == "" {
+		return bundle.Slugify(title), true
+	}
+	return slug, slugPattern.MatchString(slug)
+}
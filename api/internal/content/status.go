@@ -0,0 +1,193 @@
+package content
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// Courses start as drafts and lessons as published, so a lesson shows up
+// once its course does. Students only see published content whose
+// publish_at, if set, has passed. Archived content is no longer listed but
+// can still be opened, so old links and progress keep working.
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+var statuses = []string{StatusDraft, StatusPublished, StatusArchived}
+
+// listed reports whether students see the content in course and lesson lists.
+func listed(status string, publishAt *time.Time, now time.Time) bool {
+	return status == StatusPublished && (publishAt == nil || !publishAt.After(now))
+}
+
+// reachable reports whether students can open the content directly.
+func reachable(status string, publishAt *time.Time, now time.Time) bool {
+	return status == StatusArchived || listed(status, publishAt, now)
+}
+
+// lessonReachable reports whether students can open a lesson, which also
+// needs its course to be reachable. Unknown lessons are not.
+func (h *Handler) lessonReachable(ctx context.Context, lessonID uuid.UUID) (bool, error) {
+	v, err := h.DB.GetLessonVisibility(ctx, lessonID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	return reachable(v.CourseStatus, v.CoursePublishAt, now) && reachable(v.LessonStatus, v.LessonPublishAt, now), nil
+}
+
+// resolveStatus applies the default status and checks that publish_at is
+// only used to schedule publishing.
+func resolveStatus(status, fallback string, publishAt *time.Time) (string, *time.Time, bool) {
+	if status == "" {
+		status = fallback
+	}
+	if !slices.Contains(statuses, status) {
+		return "", nil, false
+	}
+	if publishAt != nil {
+		if status != StatusPublished {
+			return "", nil, false
+		}
+		utc := publishAt.UTC() // Stored without a time zone
+		publishAt = &utc
+	}
+	return status, publishAt, true
+}
+
+const invalidStatus = "status must be draft, published or archived, and publish_at can only be set when publishing"
+
+func writeInvalidStatus(w http.ResponseWriter) {
+	w.WriteHeader(422)
+	w.Write([]byte(`{"error": "` + invalidStatus + `"}`))
+}
+
+type statusParameters struct {
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"` // Schedules a published row
+}
+
+// SetCourseStatus publishes, unpublishes or archives a course. Sending
+// publish_at with "published" schedules it, leaving it out publishes now.
+func (h *Handler) SetCourseStatus(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var params statusParameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Status == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "status is required"}`))
+		return
+	}
+	status, publishAt, ok := resolveStatus(params.Status, "", params.PublishAt)
+	if !ok {
+		writeInvalidStatus(w)
+		return
+	}
+
+	var course database.Course
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		course, err = q.SetCourseStatus(r.Context(), database.SetCourseStatusParams{
+			ID:        id,
+			Status:    status,
+			PublishAt: publishAt,
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionCourse, course.ID, actionUpdated, user, course)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Course not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(course)
+}
+
+// SetLessonStatus is SetCourseStatus for a single lesson.
+func (h *Handler) SetLessonStatus(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("lesson_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var params statusParameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Status == "" {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "status is required"}`))
+		return
+	}
+	status, publishAt, ok := resolveStatus(params.Status, "", params.PublishAt)
+	if !ok {
+		writeInvalidStatus(w)
+		return
+	}
+
+	var lesson database.Lesson
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		lesson, err = q.SetLessonStatus(r.Context(), database.SetLessonStatusParams{
+			ID:        id,
+			Status:    status,
+			PublishAt: publishAt,
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionLesson, lesson.ID, actionUpdated, user, lesson)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Lesson not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lesson)
+}
+
+// ListCourses lists every course with its status, drafts included.
+func (h *Handler) ListCourses(w http.ResponseWriter, r *http.Request, user database.User) {
+	courses, err := h.DB.GetCourses(r.Context())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if courses == nil {
+		courses = []database.Course{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(courses)
+}
+
+// PreviewLessons answers like GET /courses/{course_id}/lessons would once
+// the course and its draft and scheduled lessons are published.
+func (h *Handler) PreviewLessons(w http.ResponseWriter, r *http.Request, user database.User) {
+	h.writeLessons(w, r, user, true)
+}
+
+// PreviewTask answers like GET /lessons/{lesson_id}/task would once the
+// lesson and its course are published.
+func (h *Handler) PreviewTask(w http.ResponseWriter, r *http.Request, user database.User) {
+	h.writeTask(w, r, true)
+}
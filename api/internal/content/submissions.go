@@ -0,0 +1,64 @@
+package content
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/paging"
+	"github.com/google/uuid"
+)
+
+// GetMySubmissions lists every attempt the logged-in user made on a task,
+// newest first.
+func (h *Handler) GetMySubmissions(w http.ResponseWriter, r *http.Request, user database.User) {
+	taskID, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	submissions, err := h.DB.GetSubmissionsByUserAndTask(r.Context(), database.GetSubmissionsByUserAndTaskParams{
+		UserID: user.ID,
+		TaskID: taskID,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if submissions == nil {
+		submissions = []database.Submission{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(submissions)
+}
+
+// Admin
+
+// GetLessonSubmissions lets instructors browse every student's attempts on
+// a lesson's task.
+func (h *Handler) GetLessonSubmissions(w http.ResponseWriter, r *http.Request, user database.User) {
+	lessonID, err := uuid.Parse(r.PathValue("lesson_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	limit, offset := paging.Params(r)
+	submissions, err := h.DB.GetSubmissionsByLessonID(r.Context(), database.GetSubmissionsByLessonIDParams{
+		LessonID: lessonID,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if submissions == nil {
+		submissions = []database.GetSubmissionsByLessonIDRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(submissions)
+}
@@ -0,0 +1,175 @@
+package content
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/matcher"
+	"github.com/google/uuid"
+)
+
+// StepOutput is what came out of running one step, either on the student's
+// machine or in the server-side runner.
+type StepOutput struct {
+	Position int32  `json:"position"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type StepResult struct {
+	Position       int32  `json:"position"`
+	Passed         bool   `json:"passed"`
+	MatchMode      string `json:"match_mode"`
+	ExpectedOutput string `json:"expected_output"`
+	ActualOutput   string `json:"actual_output"`
+	Stderr         string `json:"stderr,omitempty"`
+	ExitCode       int    `json:"exit_code"`
+	Error          string `json:"error,omitempty"`
+}
+
+type SubmissionReport struct {
+	SubmissionID string       `json:"submission_id"`
+	TaskID       string       `json:"task_id"`
+	Passed       bool         `json:"passed"`
+	Steps        []StepResult `json:"steps"`
+}
+
+// gradeSteps checks the output the client submitted for every step with that
+// step's matcher. A missing step counts as a failure, and a task without
+// steps is never passed.
+func gradeSteps(steps []database.TaskStep, outputs []StepOutput) ([]StepResult, bool) {
+	byPosition := make(map[int32]StepOutput, len(outputs))
+	for _, o := range outputs {
+		byPosition[o.Position] = o
+	}
+
+	results := make([]StepResult, 0, len(steps))
+	allPassed := len(steps) > 0
+	for _, s := range steps {
+		result := StepResult{
+			Position:       s.Position,
+			MatchMode:      s.MatchMode,
+			ExpectedOutput: s.ExpectedOutput,
+		}
+
+		m, err := matcher.New(matcher.Mode(s.MatchMode), s.ExpectedOutput, s.Tolerance)
+
+		out, ok := byPosition[s.Position]
+		switch {
+		case err != nil:
+			log.Printf("Step %s has an unusable matcher: %s", s.ID, err)
+			result.Error = "this step cannot be checked, please contact an admin"
+		case !ok:
+			result.Error = "no output submitted for this step"
+		case out.ExitCode != 0:
+			result.ActualOutput = out.Stdout
+			result.Stderr = out.Stderr
+			result.ExitCode = out.ExitCode
+			result.Error = "command exited with a non-zero status"
+		default:
+			result.ActualOutput = out.Stdout
+			result.Stderr = out.Stderr
+			result.Passed = m.Match(out.Stdout)
+		}
+
+		if !result.Passed {
+			allPassed = false
+		}
+		results = append(results, result)
+	}
+
+	return results, allPassed
+}
+
+func (h *Handler) SubmitTask(w http.ResponseWriter, r *http.Request, user database.User) {
+	taskID, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Steps []StepOutput `json:"steps"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	task, err := h.DB.GetTask(r.Context(), taskID)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+	if ok, err := h.lessonReachable(r.Context(), task.LessonID); err != nil || !ok {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "Task not found"}`))
+		return
+	}
+
+	steps, err := h.DB.GetStepsByTaskID(r.Context(), task.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	response, err := h.recordSubmission(r.Context(), user, task, steps, params.Steps)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(response)
+}
+
+// recordSubmission grades the outputs and stores the attempt. Every attempt is
+// recorded, but only a fully passing one counts as a completion.
+func (h *Handler) recordSubmission(ctx context.Context, user database.User, task database.Task, steps []database.TaskStep, outputs []StepOutput) (SubmissionReport, error) {
+	results, passed := gradeSteps(steps, outputs)
+
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return SubmissionReport{}, err
+	}
+
+	var submission database.Submission
+	err = h.withTx(ctx, func(q *database.Queries) error {
+		var err error
+		submission, err = q.CreateSubmission(ctx, database.CreateSubmissionParams{
+			UserID:  user.ID,
+			TaskID:  task.ID,
+			Passed:  passed,
+			Results: resultsJSON,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !passed {
+			return nil
+		}
+		return q.CompleteTask(ctx, database.CompleteTaskParams{
+			UserID: user.ID,
+			TaskID: task.ID,
+		})
+	})
+	if err != nil {
+		return SubmissionReport{}, err
+	}
+
+	return SubmissionReport{
+		SubmissionID: submission.ID.String(),
+		TaskID:       task.ID.String(),
+		Passed:       passed,
+		Steps:        results,
+	}, nil
+}
@@ -0,0 +1,234 @@
+package content
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/google/uuid"
+)
+
+// Deleting content moves it to the trash by setting deleted_at on the row
+// and everything below it. Restoring brings all of that back, except what
+// had been deleted on its own before, and records a revision of the
+// restored row since it may come back at another position. Rows stay in the trash for
+// TrashRetention days, then RunTrashPurge deletes them for good, together
+// with their completions, submissions and revisions.
+
+// DefaultTrashRetention is used when TRASH_RETENTION_DAYS is not set.
+const DefaultTrashRetention = 30
+
+// TrashItem is something that was deleted directly, rather than along with
+// its course, lesson or task.
+type TrashItem struct {
+	database.GetTrashRow
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+func (h *Handler) writeTrash(w http.ResponseWriter, r *http.Request, courseID uuid.NullUUID) {
+	rows, err := h.DB.GetTrash(r.Context(), courseID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	items := make([]TrashItem, 0, len(rows))
+	for _, row := range rows {
+		item := TrashItem{GetTrashRow: row}
+		if row.DeletedAt != nil {
+			item.PurgeAt = row.DeletedAt.AddDate(0, 0, int(h.TrashRetention))
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// ListTrash lists everything in the trash, newest first.
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request, user database.User) {
+	h.writeTrash(w, r, uuid.NullUUID{})
+}
+
+// ListCourseTrash lists what was deleted from a single course, and the course
+// itself if it is in the trash.
+func (h *Handler) ListCourseTrash(w http.ResponseWriter, r *http.Request, user database.User) {
+	courseID, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	h.writeTrash(w, r, uuid.NullUUID{UUID: courseID, Valid: true})
+}
+
+// writeRestoreError maps the error of a restore query to a response. Nothing
+// is found when the row isn't in the trash or its parent still is.
+func writeRestoreError(w http.ResponseWriter, err error, notFound, conflict string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "` + notFound + `"}`))
+	case isUniqueViolation(err):
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "` + conflict + `"}`))
+	default:
+		w.WriteHeader(500)
+	}
+}
+
+func (h *Handler) RestoreCourse(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var course database.Course
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		course, err = q.RestoreCourse(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionCourse, course.ID, actionRestored, user, course)
+	})
+	if err != nil {
+		// Another course may have taken the slug meanwhile
+		writeRestoreError(w, err, "Course is not in the trash", "Slug is already taken, rename the other course first")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(course)
+}
+
+// RestoreLesson puts a lesson back at its old position, or at the end of the
+// course if that position has been taken since.
+func (h *Handler) RestoreLesson(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("lesson_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var lesson database.Lesson
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		lesson, err = q.RestoreLesson(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionLesson, lesson.ID, actionRestored, user, lesson)
+	})
+	if err != nil {
+		writeRestoreError(w, err, "Lesson is not in the trash, or its course is too", "Slug is already taken, rename the other lesson first")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lesson)
+}
+
+// RestoreTask works like RestoreLesson, a taken position sends the task to
+// the end of the lesson.
+func (h *Handler) RestoreTask(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var task database.Task
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		task, err = q.RestoreTask(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionTask, task.ID, actionRestored, user, task)
+	})
+	if err != nil {
+		writeRestoreError(w, err, "Task is not in the trash, or its lesson is too", "Position is already taken")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// RestoreTaskStep works like RestoreLesson, a taken position sends the step
+// to the end of the task.
+func (h *Handler) RestoreTaskStep(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("step_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var step database.TaskStep
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		step, err = q.RestoreTaskStep(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionStep, step.ID, actionRestored, user, step)
+	})
+	if err != nil {
+		writeRestoreError(w, err, "Step is not in the trash, or its task is too", "Position is already taken")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(step)
+}
+
+// PurgeTrash deletes everything that has been in the trash for longer than
+// TrashRetention days and returns how many rows went. Children go first, so
+// each query only removes rows that were in the trash themselves.
+func (h *Handler) PurgeTrash(ctx context.Context) (int64, error) {
+	var total int64
+	err := h.withTx(ctx, func(q *database.Queries) error {
+		for _, purge := range []func(context.Context, int32) (int64, error){
+			q.PurgeTaskSteps,
+			q.PurgeTasks,
+			q.PurgeLessons,
+			q.PurgeCourses,
+		} {
+			n, err := purge(ctx, h.TrashRetention)
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	return total, err
+}
+
+// RunTrashPurge purges the trash right away and then once every interval,
+// until ctx is done.
+func (h *Handler) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := h.PurgeTrash(ctx)
+		if err != nil {
+			log.Printf("Purging the trash failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d rows from the trash", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package content
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/database"
+	"github.com/Tikkaaa3/t-learn/api/internal/matcher"
+	"github.com/google/uuid"
+)
+
+// The update handlers serve both PUT and PATCH. PUT replaces every editable
+// field and rejects bodies that leave one out, PATCH only touches the fields
+// that are present. Either way the row keeps its ID, so task_completions
+// pointing at it survive the edit.
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func nullInt32(n *int32) sql.NullInt32 {
+	if n == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *n, Valid: true}
+}
+
+func nullFloat64(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+// writeUpdateError maps the error of an UPDATE ... RETURNING query to a response.
+func writeUpdateError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		w.WriteHeader(404)
+		w.Write([]byte(`{"error": "` + notFound + `"}`))
+	case isUniqueViolation(err):
+		w.WriteHeader(409)
+		w.Write([]byte(`{"error": "` + conflictMessage(err) + `"}`))
+	case isCheckViolation(err):
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Invalid value"}`))
+	default:
+		w.WriteHeader(500)
+	}
+}
+
+func (h *Handler) UpdateCourse(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("course_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Slug        *string `json:"slug"` // Optional even for PUT
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	if r.Method == http.MethodPut && (params.Title == nil || params.Description == nil) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "PUT requires title and description"}`))
+		return
+	}
+
+	if params.Slug != nil && !slugPattern.MatchString(*params.Slug) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Slug may only contain lowercase letters, digits and single dashes"}`))
+		return
+	}
+
+	var course database.Course
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		course, err = q.UpdateCourse(r.Context(), database.UpdateCourseParams{
+			ID:          id,
+			Title:       nullString(params.Title),
+			Description: nullString(params.Description),
+			Slug:        nullString(params.Slug),
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionCourse, course.ID, actionUpdated, user, course)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Course not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(course)
+}
+
+func (h *Handler) UpdateLesson(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("lesson_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Title    *string `json:"title"`
+		Content  *string `json:"content"`
+		Position *int32  `json:"position"`
+		Slug     *string `json:"slug"` // Optional even for PUT
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	if r.Method == http.MethodPut && (params.Title == nil || params.Content == nil || params.Position == nil) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "PUT requires title, content and position"}`))
+		return
+	}
+
+	if params.Slug != nil && !slugPattern.MatchString(*params.Slug) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error": "Slug may only contain lowercase letters, digits and single dashes"}`))
+		return
+	}
+
+	var lesson database.Lesson
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		lesson, err = q.UpdateLesson(r.Context(), database.UpdateLessonParams{
+			ID:       id,
+			Title:    nullString(params.Title),
+			Content:  nullString(params.Content),
+			Position: nullInt32(params.Position),
+			Slug:     nullString(params.Slug),
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionLesson, lesson.ID, actionUpdated, user, lesson)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Lesson not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lesson)
+}
+
+func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("task_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Description *string `json:"description"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	if r.Method == http.MethodPut && params.Description == nil {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "PUT requires description"}`))
+		return
+	}
+
+	var task database.Task
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		task, err = q.UpdateTask(r.Context(), database.UpdateTaskParams{
+			ID:          id,
+			Description: nullString(params.Description),
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionTask, task.ID, actionUpdated, user, task)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Task not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *Handler) UpdateTaskStep(w http.ResponseWriter, r *http.Request, user database.User) {
+	id, err := uuid.Parse(r.PathValue("step_id"))
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	type parameters struct {
+		Command        *string  `json:"command"`
+		ExpectedOutput *string  `json:"expected_output"`
+		Position       *int32   `json:"position"`
+		MatchMode      *string  `json:"match_mode"`
+		Tolerance      *float64 `json:"tolerance"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	if r.Method == http.MethodPut && (params.Command == nil || params.ExpectedOutput == nil || params.Position == nil) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "PUT requires command, expected_output and position"}`))
+		return
+	}
+
+	// The matcher depends on several columns, so validate the step as it
+	// will look after the update rather than just the fields that were sent
+	current, err := h.DB.GetTaskStep(r.Context(), id)
+	if err != nil {
+		writeUpdateError(w, err, "Step not found")
+		return
+	}
+	expected, mode, tolerance := current.ExpectedOutput, current.MatchMode, current.Tolerance
+	if params.ExpectedOutput != nil {
+		expected = *params.ExpectedOutput
+	}
+	if params.MatchMode != nil {
+		mode = string(matchModeOrDefault(*params.MatchMode))
+		params.MatchMode = &mode
+	}
+	if params.Tolerance != nil {
+		tolerance = *params.Tolerance
+	}
+	if _, err := matcher.New(matcher.Mode(mode), expected, tolerance); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	var step database.TaskStep
+	err = h.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		step, err = q.UpdateTaskStep(r.Context(), database.UpdateTaskStepParams{
+			ID:             id,
+			Command:        nullString(params.Command),
+			ExpectedOutput: nullString(params.ExpectedOutput),
+			Position:       nullInt32(params.Position),
+			MatchMode:      nullString(params.MatchMode),
+			Tolerance:      nullFloat64(params.Tolerance),
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(r.Context(), q, revisionStep, step.ID, actionUpdated, user, step)
+	})
+	if err != nil {
+		writeUpdateError(w, err, "Step not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(step)
+}
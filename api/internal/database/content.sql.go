@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -34,24 +36,36 @@ func (q *Queries) CompleteTask(ctx context.Context, arg CompleteTaskParams) erro
 }
 
 const createCourse = `-- name: CreateCourse :one
-INSERT INTO courses (id, created_at, updated_at, title, description)
+INSERT INTO courses (id, created_at, updated_at, title, description, slug, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, title, description
+RETURNING id, created_at, updated_at, title, description, slug, status, publish_at, deleted_at
 `
 
 type CreateCourseParams struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Slug        string     `json:"slug"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
 }
 
 func (q *Queries) CreateCourse(ctx context.Context, arg CreateCourseParams) (Course, error) {
-	row := q.db.QueryRowContext(ctx, createCourse, arg.Title, arg.Description)
+	row := q.db.QueryRowContext(ctx, createCourse,
+		arg.Title,
+		arg.Description,
+		arg.Slug,
+		arg.Status,
+		arg.PublishAt,
+	)
 	var i Course
 	err := row.Scan(
 		&i.ID,
@@ -59,12 +73,16 @@ func (q *Queries) CreateCourse(ctx context.Context, arg CreateCourseParams) (Cou
 		&i.UpdatedAt,
 		&i.Title,
 		&i.Description,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const createLesson = `-- name: CreateLesson :one
-INSERT INTO lessons (id, created_at, updated_at, course_id, title, content, "position")
+INSERT INTO lessons (id, created_at, updated_at, course_id, title, content, "position", slug, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
@@ -72,16 +90,22 @@ VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, course_id, title, content, position
+RETURNING id, created_at, updated_at, course_id, title, content, position, slug, status, publish_at, deleted_at
 `
 
 type CreateLessonParams struct {
-	CourseID uuid.UUID `json:"course_id"`
-	Title    string    `json:"title"`
-	Content  string    `json:"content"`
-	Position int32     `json:"position"`
+	CourseID  uuid.UUID  `json:"course_id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Position  int32      `json:"position"`
+	Slug      string     `json:"slug"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 func (q *Queries) CreateLesson(ctx context.Context, arg CreateLessonParams) (Lesson, error) {
@@ -90,6 +114,9 @@ func (q *Queries) CreateLesson(ctx context.Context, arg CreateLessonParams) (Les
 		arg.Title,
 		arg.Content,
 		arg.Position,
+		arg.Slug,
+		arg.Status,
+		arg.PublishAt,
 	)
 	var i Lesson
 	err := row.Scan(
@@ -100,29 +127,35 @@ func (q *Queries) CreateLesson(ctx context.Context, arg CreateLessonParams) (Les
 		&i.Title,
 		&i.Content,
 		&i.Position,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const createTask = `-- name: CreateTask :one
-INSERT INTO tasks (id, created_at, updated_at, lesson_id, description)
+INSERT INTO tasks (id, created_at, updated_at, lesson_id, description, position)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, lesson_id, description
+RETURNING id, created_at, updated_at, lesson_id, description, deleted_at, position
 `
 
 type CreateTaskParams struct {
 	LessonID    uuid.UUID `json:"lesson_id"`
 	Description string    `json:"description"`
+	Position    int32     `json:"position"`
 }
 
 func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error) {
-	row := q.db.QueryRowContext(ctx, createTask, arg.LessonID, arg.Description)
+	row := q.db.QueryRowContext(ctx, createTask, arg.LessonID, arg.Description, arg.Position)
 	var i Task
 	err := row.Scan(
 		&i.ID,
@@ -130,12 +163,14 @@ func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, e
 		&i.UpdatedAt,
 		&i.LessonID,
 		&i.Description,
+		&i.DeletedAt,
+		&i.Position,
 	)
 	return i, err
 }
 
 const createTaskStep = `-- name: CreateTaskStep :one
-INSERT INTO task_steps (id, created_at, updated_at, task_id, position, command, expected_output)
+INSERT INTO task_steps (id, created_at, updated_at, task_id, position, command, expected_output, match_mode, tolerance)
 VALUES (
     gen_random_uuid(),
     NOW(),
@@ -143,9 +178,11 @@ VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6
 )
-RETURNING id, task_id, position, command, expected_output, created_at, updated_at
+RETURNING id, task_id, position, command, expected_output, created_at, updated_at, match_mode, tolerance, deleted_at
 `
 
 type CreateTaskStepParams struct {
@@ -153,6 +190,8 @@ type CreateTaskStepParams struct {
 	Position       int32     `json:"position"`
 	Command        string    `json:"command"`
 	ExpectedOutput string    `json:"expected_output"`
+	MatchMode      string    `json:"match_mode"`
+	Tolerance      float64   `json:"tolerance"`
 }
 
 func (q *Queries) CreateTaskStep(ctx context.Context, arg CreateTaskStepParams) (TaskStep, error) {
@@ -161,6 +200,8 @@ func (q *Queries) CreateTaskStep(ctx context.Context, arg CreateTaskStepParams)
 		arg.Position,
 		arg.Command,
 		arg.ExpectedOutput,
+		arg.MatchMode,
+		arg.Tolerance,
 	)
 	var i TaskStep
 	err := row.Scan(
@@ -171,39 +212,25 @@ func (q *Queries) CreateTaskStep(ctx context.Context, arg CreateTaskStepParams)
 		&i.ExpectedOutput,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.MatchMode,
+		&i.Tolerance,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const deleteCourse = `-- name: DeleteCourse :exec
-DELETE FROM courses WHERE id = $1
-`
-
-func (q *Queries) DeleteCourse(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteCourse, id)
-	return err
-}
-
-const deleteLesson = `-- name: DeleteLesson :exec
-DELETE FROM lessons WHERE id = $1
-`
-
-func (q *Queries) DeleteLesson(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteLesson, id)
-	return err
-}
-
-const deleteTask = `-- name: DeleteTask :exec
-DELETE FROM tasks WHERE id = $1
+const deferPositionConstraints = `-- name: DeferPositionConstraints :exec
+SET CONSTRAINTS unique_course_lesson_position, unique_lesson_task_position, unique_task_step_position DEFERRED
 `
 
-func (q *Queries) DeleteTask(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteTask, id)
+// Lets a transaction move positions through each other, see ReorderLessons.
+func (q *Queries) DeferPositionConstraints(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deferPositionConstraints)
 	return err
 }
 
 const getCourse = `-- name: GetCourse :one
-SELECT id, created_at, updated_at, title, description FROM courses WHERE id = $1
+SELECT id, created_at, updated_at, title, description, slug, status, publish_at, deleted_at FROM courses WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetCourse(ctx context.Context, id uuid.UUID) (Course, error) {
@@ -215,12 +242,17 @@ func (q *Queries) GetCourse(ctx context.Context, id uuid.UUID) (Course, error) {
 		&i.UpdatedAt,
 		&i.Title,
 		&i.Description,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getCourses = `-- name: GetCourses :many
-SELECT id, created_at, updated_at, title, description FROM courses
+SELECT id, created_at, updated_at, title, description, slug, status, publish_at, deleted_at FROM courses
+WHERE deleted_at IS NULL
 ORDER BY created_at DESC
 `
 
@@ -239,6 +271,10 @@ func (q *Queries) GetCourses(ctx context.Context) ([]Course, error) {
 			&i.UpdatedAt,
 			&i.Title,
 			&i.Description,
+			&i.Slug,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -254,7 +290,7 @@ func (q *Queries) GetCourses(ctx context.Context) ([]Course, error) {
 }
 
 const getLesson = `-- name: GetLesson :one
-SELECT id, created_at, updated_at, course_id, title, content, position FROM lessons WHERE id = $1
+SELECT id, created_at, updated_at, course_id, title, content, position, slug, status, publish_at, deleted_at FROM lessons WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetLesson(ctx context.Context, id uuid.UUID) (Lesson, error) {
@@ -268,13 +304,81 @@ func (q *Queries) GetLesson(ctx context.Context, id uuid.UUID) (Lesson, error) {
 		&i.Title,
 		&i.Content,
 		&i.Position,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getLessonReadsByUserID = `-- name: GetLessonReadsByUserID :many
+SELECT id, created_at, user_id, lesson_id FROM lesson_reads
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetLessonReadsByUserID(ctx context.Context, userID uuid.UUID) ([]LessonRead, error) {
+	rows, err := q.db.QueryContext(ctx, getLessonReadsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LessonRead
+	for rows.Next() {
+		var i LessonRead
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.LessonID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLessonVisibility = `-- name: GetLessonVisibility :one
+SELECT
+    l.status AS lesson_status,
+    l.publish_at AS lesson_publish_at,
+    c.status AS course_status,
+    c.publish_at AS course_publish_at
+FROM lessons l
+JOIN courses c ON c.id = l.course_id
+WHERE l.id = $1 AND l.deleted_at IS NULL
+`
+
+type GetLessonVisibilityRow struct {
+	LessonStatus    string     `json:"lesson_status"`
+	LessonPublishAt *time.Time `json:"lesson_publish_at"`
+	CourseStatus    string     `json:"course_status"`
+	CoursePublishAt *time.Time `json:"course_publish_at"`
+}
+
+func (q *Queries) GetLessonVisibility(ctx context.Context, id uuid.UUID) (GetLessonVisibilityRow, error) {
+	row := q.db.QueryRowContext(ctx, getLessonVisibility, id)
+	var i GetLessonVisibilityRow
+	err := row.Scan(
+		&i.LessonStatus,
+		&i.LessonPublishAt,
+		&i.CourseStatus,
+		&i.CoursePublishAt,
 	)
 	return i, err
 }
 
 const getLessonsByCourseID = `-- name: GetLessonsByCourseID :many
-SELECT id, created_at, updated_at, course_id, title, content, position FROM lessons 
-WHERE course_id = $1 
+SELECT id, created_at, updated_at, course_id, title, content, position, slug, status, publish_at, deleted_at FROM lessons 
+WHERE course_id = $1 AND deleted_at IS NULL
 ORDER BY "position" ASC
 `
 
@@ -295,6 +399,10 @@ func (q *Queries) GetLessonsByCourseID(ctx context.Context, courseID uuid.UUID)
 			&i.Title,
 			&i.Content,
 			&i.Position,
+			&i.Slug,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -310,21 +418,29 @@ func (q *Queries) GetLessonsByCourseID(ctx context.Context, courseID uuid.UUID)
 }
 
 const getLessonsWithStatus = `-- name: GetLessonsWithStatus :many
-SELECT 
-    l.id, 
-    l.title, 
+-- it was marked read.
+SELECT
+    l.id,
+    l.title,
     l.position,
     l.course_id,
-    CASE 
-        WHEN tc.task_id IS NOT NULL THEN true 
-        ELSE false 
-    END as is_completed
+    l.status,
+    l.publish_at,
+    COUNT(t.id) AS task_count,
+    CASE
+        WHEN COUNT(t.id) = 0 THEN EXISTS (
+            SELECT 1 FROM lesson_reads lr
+            WHERE lr.lesson_id = l.id AND lr.user_id = $2
+        )
+        ELSE COUNT(t.id) = COUNT(tc.task_id)
+    END::boolean AS is_completed
 FROM lessons l
-LEFT JOIN tasks t ON t.lesson_id = l.id  -- <--- CHANGE TO 'LEFT JOIN'
-LEFT JOIN task_completions tc 
-    ON tc.task_id = t.id 
+LEFT JOIN tasks t ON t.lesson_id = l.id AND t.deleted_at IS NULL
+LEFT JOIN task_completions tc
+    ON tc.task_id = t.id
     AND tc.user_id = $2
-WHERE l.course_id = $1
+WHERE l.course_id = $1 AND l.deleted_at IS NULL
+GROUP BY l.id
 ORDER BY l.position
 `
 
@@ -334,13 +450,18 @@ type GetLessonsWithStatusParams struct {
 }
 
 type GetLessonsWithStatusRow struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Position    int32     `json:"position"`
-	CourseID    uuid.UUID `json:"course_id"`
-	IsCompleted bool      `json:"is_completed"`
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Position    int32      `json:"position"`
+	CourseID    uuid.UUID  `json:"course_id"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	TaskCount   int64      `json:"task_count"`
+	IsCompleted bool       `json:"is_completed"`
 }
 
+// A lesson is completed once all of its tasks are, or, if it has none, once
+// it was marked read.
 func (q *Queries) GetLessonsWithStatus(ctx context.Context, arg GetLessonsWithStatusParams) ([]GetLessonsWithStatusRow, error) {
 	rows, err := q.db.QueryContext(ctx, getLessonsWithStatus, arg.CourseID, arg.UserID)
 	if err != nil {
@@ -355,6 +476,9 @@ func (q *Queries) GetLessonsWithStatus(ctx context.Context, arg GetLessonsWithSt
 			&i.Title,
 			&i.Position,
 			&i.CourseID,
+			&i.Status,
+			&i.PublishAt,
+			&i.TaskCount,
 			&i.IsCompleted,
 		); err != nil {
 			return nil, err
@@ -370,9 +494,48 @@ func (q *Queries) GetLessonsWithStatus(ctx context.Context, arg GetLessonsWithSt
 	return items, nil
 }
 
+const getNextLessonPosition = `-- name: GetNextLessonPosition :one
+SELECT (COALESCE(MAX("position"), 0) + 1)::int AS next_position
+FROM lessons
+WHERE course_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetNextLessonPosition(ctx context.Context, courseID uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getNextLessonPosition, courseID)
+	var next_position int32
+	err := row.Scan(&next_position)
+	return next_position, err
+}
+
+const getNextTaskPosition = `-- name: GetNextTaskPosition :one
+SELECT (COALESCE(MAX(position), 0) + 1)::int AS next_position
+FROM tasks
+WHERE lesson_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetNextTaskPosition(ctx context.Context, lessonID uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getNextTaskPosition, lessonID)
+	var next_position int32
+	err := row.Scan(&next_position)
+	return next_position, err
+}
+
+const getNextTaskStepPosition = `-- name: GetNextTaskStepPosition :one
+SELECT (COALESCE(MAX(position), 0) + 1)::int AS next_position
+FROM task_steps
+WHERE task_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetNextTaskStepPosition(ctx context.Context, taskID uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getNextTaskStepPosition, taskID)
+	var next_position int32
+	err := row.Scan(&next_position)
+	return next_position, err
+}
+
 const getStepsByTaskID = `-- name: GetStepsByTaskID :many
-SELECT id, task_id, position, command, expected_output, created_at, updated_at FROM task_steps 
-WHERE task_id = $1 
+SELECT id, task_id, position, command, expected_output, created_at, updated_at, match_mode, tolerance, deleted_at FROM task_steps 
+WHERE task_id = $1 AND deleted_at IS NULL
 ORDER BY position ASC
 `
 
@@ -393,6 +556,9 @@ func (q *Queries) GetStepsByTaskID(ctx context.Context, taskID uuid.UUID) ([]Tas
 			&i.ExpectedOutput,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.MatchMode,
+			&i.Tolerance,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -407,12 +573,431 @@ func (q *Queries) GetStepsByTaskID(ctx context.Context, taskID uuid.UUID) ([]Tas
 	return items, nil
 }
 
-const getTaskByLessonID = `-- name: GetTaskByLessonID :one
-SELECT id, created_at, updated_at, lesson_id, description FROM tasks WHERE lesson_id = $1
+const getTask = `-- name: GetTask :one
+SELECT id, created_at, updated_at, lesson_id, description, deleted_at, position FROM tasks WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetTask(ctx context.Context, id uuid.UUID) (Task, error) {
+	row := q.db.QueryRowContext(ctx, getTask, id)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LessonID,
+		&i.Description,
+		&i.DeletedAt,
+		&i.Position,
+	)
+	return i, err
+}
+
+const getTaskCompletionsByUserID = `-- name: GetTaskCompletionsByUserID :many
+SELECT id, created_at, updated_at, user_id, task_id FROM task_completions
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetTaskCompletionsByUserID(ctx context.Context, userID uuid.UUID) ([]TaskCompletion, error) {
+	rows, err := q.db.QueryContext(ctx, getTaskCompletionsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TaskCompletion
+	for rows.Next() {
+		var i TaskCompletion
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.TaskID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTaskStep = `-- name: GetTaskStep :one
+SELECT id, task_id, position, command, expected_output, created_at, updated_at, match_mode, tolerance, deleted_at FROM task_steps WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetTaskStep(ctx context.Context, id uuid.UUID) (TaskStep, error) {
+	row := q.db.QueryRowContext(ctx, getTaskStep, id)
+	var i TaskStep
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.Position,
+		&i.Command,
+		&i.ExpectedOutput,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MatchMode,
+		&i.Tolerance,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getTasksByLessonID = `-- name: GetTasksByLessonID :many
+SELECT id, created_at, updated_at, lesson_id, description, deleted_at, position FROM tasks
+WHERE lesson_id = $1 AND deleted_at IS NULL
+ORDER BY position ASC
+`
+
+func (q *Queries) GetTasksByLessonID(ctx context.Context, lessonID uuid.UUID) ([]Task, error) {
+	rows, err := q.db.QueryContext(ctx, getTasksByLessonID, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Task
+	for rows.Next() {
+		var i Task
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LessonID,
+			&i.Description,
+			&i.DeletedAt,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockTask = `-- name: LockTask :exec
+SELECT id FROM tasks WHERE id = $1 FOR UPDATE
+`
+
+// Holds back other changes to the task and its steps until the transaction ends.
+func (q *Queries) LockTask(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockTask, id)
+	return err
+}
+
+const markLessonRead = `-- name: MarkLessonRead :exec
+INSERT INTO lesson_reads (id, created_at, user_id, lesson_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1, -- User ID
+    $2  -- Lesson ID
+)
+ON CONFLICT (user_id, lesson_id) DO NOTHING
+`
+
+type MarkLessonReadParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	LessonID uuid.UUID `json:"lesson_id"`
+}
+
+func (q *Queries) MarkLessonRead(ctx context.Context, arg MarkLessonReadParams) error {
+	_, err := q.db.ExecContext(ctx, markLessonRead, arg.UserID, arg.LessonID)
+	return err
+}
+
+const setCourseStatus = `-- name: SetCourseStatus :one
+UPDATE courses
+SET status = $2,
+    publish_at = $3,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, title, description, slug, status, publish_at, deleted_at
+`
+
+type SetCourseStatusParams struct {
+	ID        uuid.UUID  `json:"id"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func (q *Queries) SetCourseStatus(ctx context.Context, arg SetCourseStatusParams) (Course, error) {
+	row := q.db.QueryRowContext(ctx, setCourseStatus, arg.ID, arg.Status, arg.PublishAt)
+	var i Course
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Description,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setLessonPosition = `-- name: SetLessonPosition :execrows
+UPDATE lessons
+SET "position" = $3,
+    updated_at = NOW()
+WHERE id = $1 AND course_id = $2 AND deleted_at IS NULL
+`
+
+type SetLessonPositionParams struct {
+	ID       uuid.UUID `json:"id"`
+	CourseID uuid.UUID `json:"course_id"`
+	Position int32     `json:"position"`
+}
+
+func (q *Queries) SetLessonPosition(ctx context.Context, arg SetLessonPositionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setLessonPosition, arg.ID, arg.CourseID, arg.Position)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setLessonStatus = `-- name: SetLessonStatus :one
+UPDATE lessons
+SET status = $2,
+    publish_at = $3,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, course_id, title, content, position, slug, status, publish_at, deleted_at
+`
+
+type SetLessonStatusParams struct {
+	ID        uuid.UUID  `json:"id"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func (q *Queries) SetLessonStatus(ctx context.Context, arg SetLessonStatusParams) (Lesson, error) {
+	row := q.db.QueryRowContext(ctx, setLessonStatus, arg.ID, arg.Status, arg.PublishAt)
+	var i Lesson
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CourseID,
+		&i.Title,
+		&i.Content,
+		&i.Position,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setTaskPosition = `-- name: SetTaskPosition :execrows
+UPDATE tasks
+SET position = $3,
+    updated_at = NOW()
+WHERE id = $1 AND lesson_id = $2 AND deleted_at IS NULL
+`
+
+type SetTaskPositionParams struct {
+	ID       uuid.UUID `json:"id"`
+	LessonID uuid.UUID `json:"lesson_id"`
+	Position int32     `json:"position"`
+}
+
+func (q *Queries) SetTaskPosition(ctx context.Context, arg SetTaskPositionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setTaskPosition, arg.ID, arg.LessonID, arg.Position)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setTaskStepPosition = `-- name: SetTaskStepPosition :execrows
+UPDATE task_steps
+SET position = $3,
+    updated_at = NOW()
+WHERE id = $1 AND task_id = $2 AND deleted_at IS NULL
+`
+
+type SetTaskStepPositionParams struct {
+	ID       uuid.UUID `json:"id"`
+	TaskID   uuid.UUID `json:"task_id"`
+	Position int32     `json:"position"`
+}
+
+func (q *Queries) SetTaskStepPosition(ctx context.Context, arg SetTaskStepPositionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setTaskStepPosition, arg.ID, arg.TaskID, arg.Position)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const shiftLessonPositions = `-- name: ShiftLessonPositions :exec
+UPDATE lessons
+SET "position" = "position" + 1,
+    updated_at = NOW()
+WHERE course_id = $1 AND "position" > $2 AND deleted_at IS NULL
+`
+
+type ShiftLessonPositionsParams struct {
+	CourseID uuid.UUID `json:"course_id"`
+	Position int32     `json:"position"`
+}
+
+// Makes room for a lesson right after the given position.
+func (q *Queries) ShiftLessonPositions(ctx context.Context, arg ShiftLessonPositionsParams) error {
+	_, err := q.db.ExecContext(ctx, shiftLessonPositions, arg.CourseID, arg.Position)
+	return err
+}
+
+const shiftTaskPositions = `-- name: ShiftTaskPositions :exec
+UPDATE tasks
+SET position = position + 1,
+    updated_at = NOW()
+WHERE lesson_id = $1 AND position > $2 AND deleted_at IS NULL
 `
 
-func (q *Queries) GetTaskByLessonID(ctx context.Context, lessonID uuid.UUID) (Task, error) {
-	row := q.db.QueryRowContext(ctx, getTaskByLessonID, lessonID)
+type ShiftTaskPositionsParams struct {
+	LessonID uuid.UUID `json:"lesson_id"`
+	Position int32     `json:"position"`
+}
+
+// Makes room for a task right after the given position.
+func (q *Queries) ShiftTaskPositions(ctx context.Context, arg ShiftTaskPositionsParams) error {
+	_, err := q.db.ExecContext(ctx, shiftTaskPositions, arg.LessonID, arg.Position)
+	return err
+}
+
+const shiftTaskStepPositions = `-- name: ShiftTaskStepPositions :exec
+UPDATE task_steps
+SET position = position + 1,
+    updated_at = NOW()
+WHERE task_id = $1 AND position > $2 AND deleted_at IS NULL
+`
+
+type ShiftTaskStepPositionsParams struct {
+	TaskID   uuid.UUID `json:"task_id"`
+	Position int32     `json:"position"`
+}
+
+// Makes room for a step right after the given position.
+func (q *Queries) ShiftTaskStepPositions(ctx context.Context, arg ShiftTaskStepPositionsParams) error {
+	_, err := q.db.ExecContext(ctx, shiftTaskStepPositions, arg.TaskID, arg.Position)
+	return err
+}
+
+const updateCourse = `-- name: UpdateCourse :one
+UPDATE courses
+SET title = COALESCE(sqlc.narg('title'), title),
+    description = COALESCE(sqlc.narg('description'), description),
+    slug = COALESCE(sqlc.narg('slug'), slug),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, title, description, slug, status, publish_at, deleted_at
+`
+
+type UpdateCourseParams struct {
+	Title       sql.NullString `json:"title"`
+	Description sql.NullString `json:"description"`
+	Slug        sql.NullString `json:"slug"`
+	ID          uuid.UUID      `json:"id"`
+}
+
+func (q *Queries) UpdateCourse(ctx context.Context, arg UpdateCourseParams) (Course, error) {
+	row := q.db.QueryRowContext(ctx, updateCourse,
+		arg.Title,
+		arg.Description,
+		arg.Slug,
+		arg.ID,
+	)
+	var i Course
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Description,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateLesson = `-- name: UpdateLesson :one
+UPDATE lessons
+SET title = COALESCE(sqlc.narg('title'), title),
+    content = COALESCE(sqlc.narg('content'), content),
+    "position" = COALESCE(sqlc.narg('position'), "position"),
+    slug = COALESCE(sqlc.narg('slug'), slug),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, course_id, title, content, position, slug, status, publish_at, deleted_at
+`
+
+type UpdateLessonParams struct {
+	Title    sql.NullString `json:"title"`
+	Content  sql.NullString `json:"content"`
+	Position sql.NullInt32  `json:"position"`
+	Slug     sql.NullString `json:"slug"`
+	ID       uuid.UUID      `json:"id"`
+}
+
+func (q *Queries) UpdateLesson(ctx context.Context, arg UpdateLessonParams) (Lesson, error) {
+	row := q.db.QueryRowContext(ctx, updateLesson,
+		arg.Title,
+		arg.Content,
+		arg.Position,
+		arg.Slug,
+		arg.ID,
+	)
+	var i Lesson
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CourseID,
+		&i.Title,
+		&i.Content,
+		&i.Position,
+		&i.Slug,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateTask = `-- name: UpdateTask :one
+UPDATE tasks
+SET description = COALESCE(sqlc.narg('description'), description),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, lesson_id, description, deleted_at, position
+`
+
+type UpdateTaskParams struct {
+	Description sql.NullString `json:"description"`
+	ID          uuid.UUID      `json:"id"`
+}
+
+func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) (Task, error) {
+	row := q.db.QueryRowContext(ctx, updateTask, arg.Description, arg.ID)
 	var i Task
 	err := row.Scan(
 		&i.ID,
@@ -420,6 +1005,54 @@ func (q *Queries) GetTaskByLessonID(ctx context.Context, lessonID uuid.UUID) (Ta
 		&i.UpdatedAt,
 		&i.LessonID,
 		&i.Description,
+		&i.DeletedAt,
+		&i.Position,
+	)
+	return i, err
+}
+
+const updateTaskStep = `-- name: UpdateTaskStep :one
+UPDATE task_steps
+SET command = COALESCE(sqlc.narg('command'), command),
+    expected_output = COALESCE(sqlc.narg('expected_output'), expected_output),
+    position = COALESCE(sqlc.narg('position'), position),
+    match_mode = COALESCE(sqlc.narg('match_mode'), match_mode),
+    tolerance = COALESCE(sqlc.narg('tolerance'), tolerance),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING id, task_id, position, command, expected_output, created_at, updated_at, match_mode, tolerance, deleted_at
+`
+
+type UpdateTaskStepParams struct {
+	Command        sql.NullString  `json:"command"`
+	ExpectedOutput sql.NullString  `json:"expected_output"`
+	Position       sql.NullInt32   `json:"position"`
+	MatchMode      sql.NullString  `json:"match_mode"`
+	Tolerance      sql.NullFloat64 `json:"tolerance"`
+	ID             uuid.UUID       `json:"id"`
+}
+
+func (q *Queries) UpdateTaskStep(ctx context.Context, arg UpdateTaskStepParams) (TaskStep, error) {
+	row := q.db.QueryRowContext(ctx, updateTaskStep,
+		arg.Command,
+		arg.ExpectedOutput,
+		arg.Position,
+		arg.MatchMode,
+		arg.Tolerance,
+		arg.ID,
+	)
+	var i TaskStep
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.Position,
+		&i.Command,
+		&i.ExpectedOutput,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MatchMode,
+		&i.Tolerance,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -6,35 +6,146 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	KeyHash    string       `json:"key_hash"`
+	Scopes     string       `json:"scopes"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+}
+
+type ContentRevision struct {
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	EntityType string          `json:"entity_type"`
+	EntityID   uuid.UUID       `json:"entity_id"`
+	Version    int32           `json:"version"`
+	Action     string          `json:"action"`
+	AuthorID   uuid.NullUUID   `json:"author_id"`
+	Snapshot   json.RawMessage `json:"snapshot"`
+}
+
 type Course struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Slug        string     `json:"slug"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+type DeviceCode struct {
+	ID             uuid.UUID     `json:"id"`
+	CreatedAt      time.Time     `json:"created_at"`
+	DeviceCodeHash string        `json:"device_code_hash"`
+	UserCode       string        `json:"user_code"`
+	ClientName     string        `json:"client_name"`
+	Scopes         string        `json:"scopes"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	LastPolledAt   sql.NullTime  `json:"last_polled_at"`
+	UserID         uuid.NullUUID `json:"user_id"`
+	ApprovedAt     sql.NullTime  `json:"approved_at"`
+	DeniedAt       sql.NullTime  `json:"denied_at"`
 }
 
 type Lesson struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	CourseID  uuid.UUID  `json:"course_id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Position  int32      `json:"position"`
+	Slug      string     `json:"slug"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type LessonRead struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CourseID  uuid.UUID `json:"course_id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Position  int32     `json:"position"`
+	UserID    uuid.UUID `json:"user_id"`
+	LessonID  uuid.UUID `json:"lesson_id"`
+}
+
+type LoginThrottle struct {
+	ThrottleKey   string       `json:"throttle_key"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+type OidcLogin struct {
+	StateHash    string        `json:"state_hash"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Provider     string        `json:"provider"`
+	CodeVerifier string        `json:"code_verifier"`
+	Nonce        string        `json:"nonce"`
+	UserID       uuid.NullUUID `json:"user_id"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+	Reauth       bool          `json:"reauth"`
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RoleGrant struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Role      string        `json:"role"`
+	CourseID  uuid.NullUUID `json:"course_id"`
+	GrantedBy uuid.NullUUID `json:"granted_by"`
+}
+
+type Session struct {
+	ID                uuid.UUID      `json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	UserID            uuid.UUID      `json:"user_id"`
+	RefreshTokenHash  string         `json:"refresh_token_hash"`
+	PreviousTokenHash sql.NullString `json:"previous_token_hash"`
+	ExpiresAt         time.Time      `json:"expires_at"`
+	RevokedAt         sql.NullTime   `json:"revoked_at"`
+}
+
+type Submission struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UserID    uuid.UUID       `json:"user_id"`
+	TaskID    uuid.UUID       `json:"task_id"`
+	Passed    bool            `json:"passed"`
+	Results   json.RawMessage `json:"results"`
 }
 
 type Task struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	LessonID    uuid.UUID `json:"lesson_id"`
-	Description string    `json:"description"`
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LessonID    uuid.UUID  `json:"lesson_id"`
+	Description string     `json:"description"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+	Position    int32      `json:"position"`
 }
 
 type TaskCompletion struct {
@@ -46,22 +157,54 @@ type TaskCompletion struct {
 }
 
 type TaskStep struct {
-	ID             uuid.UUID `json:"id"`
-	TaskID         uuid.UUID `json:"task_id"`
-	Position       int32     `json:"position"`
-	Command        string    `json:"command"`
-	ExpectedOutput string    `json:"expected_output"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	TaskID         uuid.UUID  `json:"task_id"`
+	Position       int32      `json:"position"`
+	Command        string     `json:"command"`
+	ExpectedOutput string     `json:"expected_output"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	MatchMode      string     `json:"match_mode"`
+	Tolerance      float64    `json:"tolerance"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+}
+
+type TotpCredential struct {
+	UserID       uuid.UUID    `json:"user_id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Secret       string       `json:"secret"`
+	EnabledAt    sql.NullTime `json:"enabled_at"`
+	LastUsedStep int64        `json:"last_used_step"`
 }
 
 type User struct {
-	ID           uuid.UUID      `json:"id"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	Username     string         `json:"username"`
-	Email        string         `json:"email"`
-	PasswordHash string         `json:"password_hash"`
-	ApiKey       sql.NullString `json:"api_key"`
-	Role         string         `json:"role"`
+	ID                    uuid.UUID    `json:"id"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at"`
+	Username              string       `json:"username"`
+	Email                 string       `json:"email"`
+	PasswordHash          string       `json:"password_hash"`
+	Role                  string       `json:"role"`
+	EmailVerifiedAt       sql.NullTime `json:"email_verified_at"`
+	DisabledAt            sql.NullTime `json:"disabled_at"`
+	PasswordResetRequired bool         `json:"password_reset_required"`
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+type UserToken struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Purpose   string       `json:"purpose"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
 }
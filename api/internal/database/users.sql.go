@@ -7,11 +7,24 @@ package database
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE $1::text = ''
+    OR strpos(lower(username), lower($1)) > 0
+    OR strpos(lower(email), lower($1)) > 0
+`
+
+func (q *Queries) CountUsers(ctx context.Context, search string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers, search)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, username, email, password_hash)
 VALUES (
@@ -22,7 +35,7 @@ VALUES (
     $2,
     $3
 )
-RETURNING id, created_at, updated_at, username, email, password_hash, api_key, role
+RETURNING id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required
 `
 
 type CreateUserParams struct {
@@ -41,18 +54,60 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.ApiKey,
 		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+	)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableUser = `-- name: DisableUser :one
+UPDATE users
+SET disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, disableUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
 	)
 	return i, err
 }
 
-const getUserByAPIKey = `-- name: GetUserByAPIKey :one
-SELECT id, created_at, updated_at, username, email, password_hash, api_key, role FROM users WHERE api_key = $1
+const enableUser = `-- name: EnableUser :one
+UPDATE users
+SET disabled_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required
 `
 
-func (q *Queries) GetUserByAPIKey(ctx context.Context, apiKey sql.NullString) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByAPIKey, apiKey)
+func (q *Queries) EnableUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableUser, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -61,14 +116,38 @@ func (q *Queries) GetUserByAPIKey(ctx context.Context, apiKey sql.NullString) (U
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.ApiKey,
 		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required FROM users WHERE email = $1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, username, email, password_hash, api_key, role FROM users WHERE id = $1
+SELECT id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -81,14 +160,16 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.ApiKey,
 		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, created_at, updated_at, username, email, password_hash, api_key, role FROM users WHERE username = $1
+SELECT id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required FROM users WHERE username = $1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -101,27 +182,217 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.ApiKey,
 		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+	)
+	return i, err
+}
+
+const getUserProgress = `-- name: GetUserProgress :many
+SELECT
+    c.id AS course_id,
+    c.title,
+    COUNT(t.id) AS total_tasks,
+    COUNT(tc.id) AS completed_tasks
+FROM courses c
+JOIN lessons l ON l.course_id = c.id
+JOIN tasks t ON t.lesson_id = l.id
+LEFT JOIN task_completions tc
+    ON tc.task_id = t.id
+    AND tc.user_id = $1
+WHERE c.deleted_at IS NULL AND l.deleted_at IS NULL AND t.deleted_at IS NULL
+GROUP BY c.id, c.title
+ORDER BY c.title
+`
+
+type GetUserProgressRow struct {
+	CourseID       uuid.UUID `json:"course_id"`
+	Title          string    `json:"title"`
+	TotalTasks     int64     `json:"total_tasks"`
+	CompletedTasks int64     `json:"completed_tasks"`
+}
+
+// Completed tasks per course, counting only lessons that have a task.
+func (q *Queries) GetUserProgress(ctx context.Context, userID uuid.UUID) ([]GetUserProgressRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserProgress, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserProgressRow
+	for rows.Next() {
+		var i GetUserProgressRow
+		if err := rows.Scan(
+			&i.CourseID,
+			&i.Title,
+			&i.TotalTasks,
+			&i.CompletedTasks,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required FROM users
+WHERE $1::text = ''
+    OR strpos(lower(username), lower($1)) > 0
+    OR strpos(lower(email), lower($1)) > 0
+ORDER BY created_at
+LIMIT $2 OFFSET $3
+`
+
+type ListUsersParams struct {
+	Search     string `json:"search"`
+	PageLimit  int32  `json:"page_limit"`
+	PageOffset int32  `json:"page_offset"`
+}
+
+// A plain substring search, % and _ in it are no wildcards.
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Search, arg.PageLimit, arg.PageOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Username,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.DisabledAt,
+			&i.PasswordResetRequired,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requirePasswordReset = `-- name: RequirePasswordReset :exec
+UPDATE users
+SET password_reset_required = TRUE, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RequirePasswordReset(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, requirePasswordReset, id)
+	return err
+}
+
+const setEmailVerified = `-- name: SetEmailVerified :exec
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email_verified_at IS NULL
+`
+
+func (q *Queries) SetEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, setEmailVerified, id)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
 	)
 	return i, err
 }
 
-const updateAPIKey = `-- name: UpdateAPIKey :one
-UPDATE users 
-SET api_key = $2
-WHERE id = $1 
-RETURNING api_key
+const updatePassword = `-- name: UpdatePassword :exec
+UPDATE users
+SET password_hash = $2, password_reset_required = FALSE, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdatePasswordParams struct {
+	ID           uuid.UUID `json:"id"`
+	PasswordHash string    `json:"password_hash"`
+}
+
+// Choosing a new password completes a forced password reset.
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updatePassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET username = $2,
+    email = $3,
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, username, email, password_hash, role, email_verified_at, disabled_at, password_reset_required
 `
 
-type UpdateAPIKeyParams struct {
-	ID     uuid.UUID      `json:"id"`
-	ApiKey sql.NullString `json:"api_key"`
+type UpdateUserProfileParams struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
 }
 
-func (q *Queries) UpdateAPIKey(ctx context.Context, arg UpdateAPIKeyParams) (sql.NullString, error) {
-	row := q.db.QueryRowContext(ctx, updateAPIKey, arg.ID, arg.ApiKey)
-	var api_key sql.NullString
-	err := row.Scan(&api_key)
-	return api_key, err
+// A new email address has to be verified again.
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.ID, arg.Username, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+	)
+	return i, err
 }
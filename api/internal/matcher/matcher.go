@@ -0,0 +1,167 @@
+// Package matcher decides whether the output of a task step is correct.
+//
+// Every step stores a match mode next to its expected output. The server uses
+// this package when grading submissions, and the mode is exposed in the task
+// JSON so the CLI and the web terminal can apply the same rules locally.
+package matcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Mode string
+
+const (
+	// Exact compares the output byte for byte.
+	Exact Mode = "exact"
+	// Trimmed ignores trailing whitespace on every line and blank lines
+	// around the output. This is the default.
+	Trimmed Mode = "trimmed"
+	// Regex treats the expected output as a regular expression that must
+	// match the whole output (minus trailing newlines).
+	Regex Mode = "regex"
+	// Contains passes if the expected output appears anywhere in the output.
+	Contains Mode = "contains"
+	// JSON parses both sides and compares the values, so key order and
+	// formatting do not matter.
+	JSON Mode = "json"
+	// Numeric compares whitespace separated tokens. Tokens that parse as
+	// numbers may differ by at most the step's tolerance, all others must be
+	// equal.
+	Numeric Mode = "numeric"
+)
+
+// Default is used when a step does not specify a mode.
+const Default = Trimmed
+
+// Modes lists every supported mode.
+var Modes = []Mode{Exact, Trimmed, Regex, Contains, JSON, Numeric}
+
+type Matcher interface {
+	Match(actual string) bool
+}
+
+// New builds a matcher for one step. It fails if the mode is unknown or the
+// expected output does not make sense for it (an invalid regex or JSON
+// document, a negative tolerance).
+func New(mode Mode, expected string, tolerance float64) (Matcher, error) {
+	if mode == "" {
+		mode = Default
+	}
+
+	switch mode {
+	case Exact:
+		return exactMatcher{expected}, nil
+	case Trimmed:
+		return trimmedMatcher{normalize(expected)}, nil
+	case Regex:
+		re, err := regexp.Compile(`\A(?:` + expected + `)\z`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return regexMatcher{re}, nil
+	case Contains:
+		return containsMatcher{expected}, nil
+	case JSON:
+		var want interface{}
+		if err := json.Unmarshal([]byte(expected), &want); err != nil {
+			return nil, fmt.Errorf("expected output is not valid JSON: %w", err)
+		}
+		return jsonMatcher{want}, nil
+	case Numeric:
+		if tolerance < 0 || math.IsNaN(tolerance) {
+			return nil, errors.New("tolerance must not be negative")
+		}
+		return numericMatcher{strings.Fields(expected), tolerance}, nil
+	}
+
+	return nil, fmt.Errorf("unknown match mode %q", mode)
+}
+
+// normalize drops carriage returns, trailing whitespace on every line and
+// blank lines at both ends.
+func normalize(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+type exactMatcher struct{ expected string }
+
+func (m exactMatcher) Match(actual string) bool {
+	return actual == m.expected
+}
+
+type trimmedMatcher struct{ expected string }
+
+func (m trimmedMatcher) Match(actual string) bool {
+	return normalize(actual) == m.expected
+}
+
+type regexMatcher struct{ re *regexp.Regexp }
+
+func (m regexMatcher) Match(actual string) bool {
+	return m.re.MatchString(strings.TrimRight(actual, "\r\n"))
+}
+
+type containsMatcher struct{ expected string }
+
+func (m containsMatcher) Match(actual string) bool {
+	return strings.Contains(actual, m.expected)
+}
+
+type jsonMatcher struct{ expected interface{} }
+
+func (m jsonMatcher) Match(actual string) bool {
+	var got interface{}
+	if err := json.Unmarshal([]byte(actual), &got); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, m.expected)
+}
+
+type numericMatcher struct {
+	expected  []string
+	tolerance float64
+}
+
+func (m numericMatcher) Match(actual string) bool {
+	got := strings.Fields(actual)
+	if len(got) != len(m.expected) {
+		return false
+	}
+
+	for i, want := range m.expected {
+		wantNum, okWant := parseFinite(want)
+		gotNum, okGot := parseFinite(got[i])
+		if !okWant || !okGot {
+			if got[i] != want {
+				return false
+			}
+			continue
+		}
+		if math.Abs(wantNum-gotNum) > m.tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFinite parses a token as a number. NaN and infinities don't count,
+// every comparison with NaN is false, so "NaN" would pass for any number.
+func parseFinite(token string) (float64, bool) {
+	f, err := strconv.ParseFloat(token, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
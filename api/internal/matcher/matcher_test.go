@@ -0,0 +1,92 @@
+package matcher_test
+
+import (
+	"testing"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/matcher"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      matcher.Mode
+		expected  string
+		tolerance float64
+		actual    string
+		want      bool
+	}{
+		{"exact equal", matcher.Exact, "hello\n", 0, "hello\n", true},
+		{"exact missing newline", matcher.Exact, "hello\n", 0, "hello", false},
+		{"exact trailing space", matcher.Exact, "hello", 0, "hello ", false},
+
+		{"trimmed trailing whitespace", matcher.Trimmed, "a\nb", 0, "a  \nb\t\n", true},
+		{"trimmed blank lines around", matcher.Trimmed, "a\nb", 0, "\n\na\nb\n\n", true},
+		{"trimmed crlf", matcher.Trimmed, "a\nb", 0, "a\r\nb\r\n", true},
+		{"trimmed leading space counts", matcher.Trimmed, "a", 0, " a", false},
+		{"trimmed blank line inside counts", matcher.Trimmed, "a\nb", 0, "a\n\nb", false},
+		{"default mode is trimmed", "", "a", 0, "a \n", true},
+
+		{"regex whole output", matcher.Regex, `\d+ items`, 0, "42 items\n", true},
+		{"regex is anchored", matcher.Regex, `\d+`, 0, "42 items", false},
+		{"regex alternation is anchored", matcher.Regex, `a|b`, 0, "ab", false},
+		{"regex multiline", matcher.Regex, `(?s)start.*end`, 0, "start\nmiddle\nend\r\n", true},
+
+		{"contains", matcher.Contains, "world", 0, "hello world!", true},
+		{"contains missing", matcher.Contains, "World", 0, "hello world!", false},
+
+		{"json key order", matcher.JSON, `{"a": 1, "b": [1, 2]}`, 0, `{"b":[1,2],"a":1}`, true},
+		{"json different value", matcher.JSON, `{"a": 1}`, 0, `{"a": 2}`, false},
+		{"json invalid output", matcher.JSON, `{"a": 1}`, 0, `{"a": 1`, false},
+
+		{"numeric equal", matcher.Numeric, "1 2.5 x", 0, "1 2.5 x", true},
+		{"numeric formatting", matcher.Numeric, "1 2.5", 0, "1.0\n2.50\n", true},
+		{"numeric within tolerance", matcher.Numeric, "3.14159", 0.001, "3.1420", true},
+		{"numeric at tolerance", matcher.Numeric, "10", 0.5, "10.5", true},
+		{"numeric beyond tolerance", matcher.Numeric, "10", 0.5, "10.51", false},
+		{"numeric zero tolerance", matcher.Numeric, "0.3", 0, "0.30000000000000004", false},
+		{"numeric words must be equal", matcher.Numeric, "total 3", 1, "Total 3", false},
+		{"numeric fewer tokens", matcher.Numeric, "1 2 3", 0, "1 2", false},
+		{"numeric more tokens", matcher.Numeric, "1 2", 0, "1 2 3", false},
+		{"numeric empty output", matcher.Numeric, "1", 0, "", false},
+		{"numeric NaN output", matcher.Numeric, "1 2", 0.1, "NaN NaN", false},
+		{"numeric infinite output", matcher.Numeric, "1", 1e9, "+Inf", false},
+		{"numeric large tolerance", matcher.Numeric, "1", 1e9, "-1e8", true},
+		{"numeric expected NaN as text", matcher.Numeric, "NaN", 0.1, "NaN", true},
+		{"numeric expected NaN is not a number", matcher.Numeric, "NaN", 0.1, "1", false},
+		{"numeric overflow is not a number", matcher.Numeric, "1", 1e9, "1e400", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := matcher.New(tt.mode, tt.expected, tt.tolerance)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if got := m.Match(tt.actual); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.actual, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      matcher.Mode
+		expected  string
+		tolerance float64
+	}{
+		{"unknown mode", "fuzzy", "a", 0},
+		{"invalid regex", matcher.Regex, "(", 0},
+		{"invalid json", matcher.JSON, "{", 0},
+		{"negative tolerance", matcher.Numeric, "1", -0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := matcher.New(tt.mode, tt.expected, tt.tolerance); err == nil {
+				t.Errorf("New(%q, %q, %v) succeeded, want an error", tt.mode, tt.expected, tt.tolerance)
+			}
+		})
+	}
+}
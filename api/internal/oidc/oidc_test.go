@@ -0,0 +1,199 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc"
+	"github.com/Tikkaaa3/t-learn/api/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:8080/auth/sso/uni/callback"
+
+func newProvider(t *testing.T, server *oidctest.Server) *oidc.Generic {
+	t.Helper()
+	p, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	return p
+}
+
+// authorize follows the provider's login page like a browser and returns
+// the code and state it redirects back with.
+func authorize(t *testing.T, p oidc.Provider, state, nonce, verifier string) (code, gotState string) {
+	t.Helper()
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(p.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier)))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, want 302", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: bad Location: %v", err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != redirectURL {
+		t.Fatalf("redirected to %s, want %s", got, redirectURL)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestLogin(t *testing.T) {
+	for _, secret := range []string{"s3cret/+=", ""} {
+		server := oidctest.NewServer("t-learn", secret)
+		defer server.Close()
+		p := newProvider(t, server)
+
+		verifier, _ := oidc.NewCodeVerifier()
+		state, _ := oidc.NewState()
+		nonce, _ := oidc.NewState()
+
+		code, gotState := authorize(t, p, state, nonce, verifier)
+		if gotState != state {
+			t.Errorf("state = %q, want %q", gotState, state)
+		}
+
+		id, err := p.Exchange(context.Background(), code, verifier, nonce)
+		if err != nil {
+			t.Fatalf("Exchange (secret %q): %v", secret, err)
+		}
+		want := oidc.Identity{
+			Subject:           server.User.Subject,
+			Email:             server.User.Email,
+			EmailVerified:     true,
+			Name:              server.User.Name,
+			PreferredUsername: server.User.PreferredUsername,
+		}
+		if *id != want {
+			t.Errorf("identity = %+v, want %+v", *id, want)
+		}
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(*oidctest.Server)
+		verifier func(string) string
+		nonce    func(string) string
+		reuse    bool
+		provider bool // Whether the provider itself refuses
+	}{
+		{
+			name:     "wrong code verifier",
+			verifier: func(string) string { return "not-the-verifier" },
+			provider: true,
+		},
+		{
+			name:     "reused code",
+			reuse:    true,
+			provider: true,
+		},
+		{
+			name:  "wrong nonce",
+			nonce: func(string) string { return "replayed" },
+		},
+		{
+			name:  "expired id token",
+			setup: func(s *oidctest.Server) { s.TokenTTL = -time.Hour },
+		},
+		{
+			name:  "unpublished signing key",
+			setup: func(s *oidctest.Server) { s.ForgeSignature = true },
+		},
+		{
+			name:  "other audience",
+			setup: func(s *oidctest.Server) { s.Audience = []string{"someone-else"} },
+		},
+		{
+			name:  "shared audience without azp",
+			setup: func(s *oidctest.Server) { s.Audience = []string{"t-learn", "someone-else"} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := oidctest.NewServer("t-learn", "secret")
+			defer server.Close()
+			if tt.setup != nil {
+				tt.setup(server)
+			}
+			p := newProvider(t, server)
+
+			verifier, _ := oidc.NewCodeVerifier()
+			nonce, _ := oidc.NewState()
+			code, _ := authorize(t, p, "state", nonce, verifier)
+
+			if tt.reuse {
+				if _, err := p.Exchange(context.Background(), code, verifier, nonce); err != nil {
+					t.Fatalf("first Exchange: %v", err)
+				}
+			}
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+			if tt.nonce != nil {
+				nonce = tt.nonce(nonce)
+			}
+
+			_, err := p.Exchange(context.Background(), code, verifier, nonce)
+			if err == nil {
+				t.Fatal("Exchange succeeded, want an error")
+			}
+			if got := errors.Is(err, oidc.ErrProvider); got != tt.provider {
+				t.Errorf("errors.Is(err, ErrProvider) = %v, want %v (err: %v)", got, tt.provider, err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	server := oidctest.NewServer("t-learn", "secret")
+	defer server.Close()
+	p := newProvider(t, server)
+
+	for i := 0; i < 2; i++ {
+		verifier, _ := oidc.NewCodeVerifier()
+		nonce, _ := oidc.NewState()
+		code, _ := authorize(t, p, "state", nonce, verifier)
+		if _, err := p.Exchange(context.Background(), code, verifier, nonce); err != nil {
+			t.Fatalf("Exchange %d: %v", i, err)
+		}
+
+		// The next token is signed with a key the provider hasn't seen
+		server.RotateKey()
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	server := oidctest.NewServer("t-learn", "")
+	defer server.Close()
+
+	_, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:      server.Issuer() + "/other",
+		ClientID:    "t-learn",
+		RedirectURL: redirectURL,
+	})
+	if err == nil {
+		t.Fatal("Discover accepted a document for another issuer")
+	}
+}
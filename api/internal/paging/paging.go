@@ -0,0 +1,27 @@
+// Package paging reads the ?limit= and ?offset= parameters shared by every
+// list endpoint that pages its results.
+package paging
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultSize = 50
+	MaxSize     = 200
+)
+
+// Params reads ?limit= and ?offset= from the query string, falling back to
+// sane defaults for missing or out of range values.
+func Params(r *http.Request) (limit, offset int32) {
+	limit = DefaultSize
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= MaxSize {
+		limit = int32(n)
+	}
+	if n, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64); err == nil && n > 0 {
+		offset = int32(min(n, math.MaxInt32))
+	}
+	return limit, offset
+}
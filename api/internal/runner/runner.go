@@ -0,0 +1,202 @@
+// Package runner executes task steps on the server instead of trusting the
+// student's machine.
+//
+// Every run gets a fresh temporary directory holding the submitted source
+// files. Steps are executed one after another with /bin/sh inside that
+// directory, so a compile step can be followed by a run step. On Linux each
+// command is limited in CPU time, address space, file size and number of
+// processes, is killed after a wall clock timeout and runs in its own
+// namespaces: it has no network interfaces and only sees a size-limited copy
+// of the work directory and read-only toolchain directories like /usr, never
+// the server's files. Other platforms are not supported.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrUnsupported   = errors.New("runner: sandboxed execution is only supported on Linux")
+	ErrInvalidFile   = errors.New("runner: file names must be relative paths inside the work directory")
+	ErrFilesTooLarge = errors.New("runner: submitted files are too large")
+)
+
+type Limits struct {
+	CPUTime       time.Duration // RLIMIT_CPU per step
+	Memory        int64         // RLIMIT_AS per step, in bytes
+	FileSize      int64         // RLIMIT_FSIZE per step, in bytes
+	Processes     int64         // RLIMIT_NPROC per step, counted inside the sandbox
+	WorkSize      int64         // Size of /work, all files of a run together
+	WallTime      time.Duration // Per step, the process group is killed afterwards
+	MaxOutput     int           // Bytes kept from stdout and stderr each
+	MaxInputBytes int           // Total size of all submitted files
+}
+
+var DefaultLimits = Limits{
+	CPUTime:       5 * time.Second,
+	Memory:        1 << 30, // Compilers reserve a lot of address space
+	FileSize:      16 << 20,
+	Processes:     256, // Threads count too, and the Go toolchain starts many
+	WorkSize:      64 << 20,
+	WallTime:      15 * time.Second,
+	MaxOutput:     64 << 10,
+	MaxInputBytes: 1 << 20,
+}
+
+type Step struct {
+	Position int32
+	Command  string
+}
+
+type Result struct {
+	Position  int32  `json:"position"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	ExitCode  int    `json:"exit_code"`
+	TimedOut  bool   `json:"timed_out"`
+	Truncated bool   `json:"truncated"`
+}
+
+type Runner struct {
+	Limits  Limits
+	BaseDir string // Where work directories are created, os.TempDir() if empty. Must be reachable by nobody when the server runs as root.
+
+	slots chan struct{}
+}
+
+// New returns a runner that executes at most maxConcurrent runs at a time.
+func New(limits Limits, maxConcurrent int) *Runner {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &Runner{
+		Limits: limits,
+		slots:  make(chan struct{}, maxConcurrent),
+	}
+}
+
+// Run writes files into a fresh work directory and executes every step in
+// order. A failing step does not stop the following ones, the caller decides
+// what the results mean.
+func (r *Runner) Run(ctx context.Context, files map[string]string, steps []Step) ([]Result, error) {
+	if !supported {
+		return nil, ErrUnsupported
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+		defer func() { <-r.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	dir, err := os.MkdirTemp(r.BaseDir, "t-learn-run-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The sandbox builds each step's root file system next to the work
+	// directory
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.writeFiles(work, files); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(steps))
+	for _, step := range steps {
+		result, err := r.runStep(ctx, dir, step)
+		if err != nil {
+			return nil, fmt.Errorf("runner: step %d: %w", step.Position, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (r *Runner) writeFiles(dir string, files map[string]string) error {
+	total := 0
+	for name, content := range files {
+		total += len(content)
+		if total > r.Limits.MaxInputBytes {
+			return ErrFilesTooLarge
+		}
+		if !filepath.IsLocal(name) {
+			return ErrInvalidFile
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) runStep(ctx context.Context, dir string, step Step) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Limits.WallTime)
+	defer cancel()
+
+	stdout := &cappedBuffer{max: r.Limits.MaxOutput}
+	stderr := &cappedBuffer{max: r.Limits.MaxOutput}
+	err := sandboxRun(ctx, dir, step.Command, r.Limits, stdout, stderr)
+
+	result := Result{
+		Position:  step.Position,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		TimedOut:  errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		// 128+n when the step was killed by signal n (CPU limit), -1 when
+		// the whole sandbox was killed on timeout
+		result.ExitCode = exitErr.ExitCode()
+	case result.TimedOut:
+		result.ExitCode = -1
+	default:
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it and silently drops
+// the rest, so a runaway program cannot exhaust the server's memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
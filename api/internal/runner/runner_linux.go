@@ -0,0 +1,490 @@
+//go:build linux
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const supported = true
+
+// sandboxInit is the argv[0] the server re-executes itself with to set up
+// the sandbox from inside the new namespaces, where Go's os/exec offers no
+// hook to run code between clone and exec. It then re-executes itself once
+// more as sandboxStep, which applies the limits and becomes the shell.
+const (
+	sandboxInit = "t-learn-sandbox"
+	sandboxStep = "t-learn-sandbox-step"
+)
+
+// readOnlyPaths are made visible inside the sandbox, read-only. Everything
+// else on the host, including the server's own directory with its .env and
+// keys, is not mounted at all. Paths that don't exist are skipped, symlinks
+// (like /lib on merged /usr systems) are recreated as they are.
+var readOnlyPaths = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc/alternatives", "/etc/ld.so.cache", "/etc/ld.so.conf", "/etc/ld.so.conf.d",
+	"/etc/localtime", "/etc/passwd", "/etc/group", "/etc/nsswitch.conf", "/etc/ssl",
+	"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom",
+}
+
+// Flags of a mount that a user namespace may not drop when it remounts a
+// bind of it, statfs reports them with different values than mount takes.
+var lockedFlags = []struct{ statfs, mount uintptr }{
+	{2, syscall.MS_NOSUID},
+	{4, syscall.MS_NODEV},
+	{8, syscall.MS_NOEXEC},
+	{1024, syscall.MS_NOATIME},
+	{2048, syscall.MS_NODIRATIME},
+	{4096, syscall.MS_RELATIME},
+}
+
+const (
+	prSetDumpable   = 4
+	prSetNoNewPrivs = 38
+)
+
+// nobody is the overflow user and group ID of Linux, which owns no files.
+const nobody = 65534
+
+func init() {
+	switch {
+	case len(os.Args) == 8 && os.Args[0] == sandboxInit:
+		sandboxMain()
+	case len(os.Args) == 6 && os.Args[0] == sandboxStep:
+		stepMain()
+	}
+}
+
+// sandboxRun runs command with /bin/sh in a sandbox built from dir. The
+// process gets new user, mount, PID, network, IPC and UTS namespaces (the
+// user namespace is what allows an unprivileged server to create the
+// others) and its own process group so a timeout kills everything it
+// spawned. Inside, it sees a read-only root with only readOnlyPaths, an
+// empty /tmp, /proc and a /work tmpfs holding a copy of dir's work
+// subdirectory, which is copied back once the step is done. The shell runs
+// without any capabilities and with the rlimits already applied.
+func sandboxRun(ctx context.Context, dir, command string, limits Limits, stdout, stderr io.Writer) error {
+	if err := os.MkdirAll(filepath.Join(dir, "root"), 0o755); err != nil {
+		return err
+	}
+	uid, gid := sandboxIDs()
+	if uid != os.Getuid() {
+		if err := chownTree(dir, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	// The sandbox reports setup failures on fd 3, so they can't be confused
+	// with the output of the step. It is closed when the shell starts.
+	setupErrors, setupErrorsW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer setupErrors.Close()
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{
+		sandboxInit,
+		dir,
+		strconv.FormatInt(limits.WorkSize, 10),
+		strconv.FormatInt(int64(limits.CPUTime.Seconds()), 10),
+		strconv.FormatInt(limits.Memory, 10),
+		strconv.FormatInt(limits.FileSize, 10),
+		strconv.FormatInt(limits.Processes, 10),
+		command,
+	}
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=/usr/local/go/bin:/usr/local/bin:/usr/bin:/bin",
+		"HOME=/work",
+		"TMPDIR=/tmp",
+		"GOCACHE=/work/.cache/go-build",
+		"LANG=C.UTF-8",
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.ExtraFiles = []*os.File{setupErrorsW}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: uid, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: gid, Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+		// Become uid and gid 0 in the new namespace, a root server would
+		// otherwise stay host root there, unmapped and without capabilities
+		Credential: &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true},
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	setupErrorsW.Close()
+	if msg, _ := io.ReadAll(setupErrors); len(msg) > 0 {
+		return fmt.Errorf("sandbox setup: %s", msg)
+	}
+	return err
+}
+
+// sandboxIDs returns the host user and group the sandbox runs as: the
+// server's own, unless that is root. RLIMIT_NPROC doesn't apply to root,
+// and a step that escaped the sandbox shouldn't be root either.
+func sandboxIDs() (uid, gid int) {
+	if os.Getuid() == 0 {
+		return nobody, nobody
+	}
+	return os.Getuid(), os.Getgid()
+}
+
+// chownTree hands dir and everything in it to uid and gid.
+func chownTree(dir string, uid, gid int) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// sandboxMain runs in the re-executed server as PID 1 of the new namespaces.
+// It builds the sandbox, runs the step in it and copies /work back to the
+// host once every process of the step is gone. It never returns.
+func sandboxMain() {
+	setupErrors := os.NewFile(3, "setup errors")
+	fail := func(err error) {
+		fmt.Fprint(setupErrors, err)
+		os.Exit(125)
+	}
+
+	workSize, err := strconv.ParseUint(os.Args[2], 10, 64)
+	if err != nil {
+		fail(err)
+	}
+	// /tmp holds as much as a single file may
+	tmpSize, err := strconv.ParseUint(os.Args[5], 10, 64)
+	if err != nil {
+		fail(err)
+	}
+	host, err := enterSandbox(os.Args[1], workSize, tmpSize)
+	if err != nil {
+		fail(err)
+	}
+
+	// The step must not be able to read our memory, which holds the only
+	// way back to the host's file system
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetDumpable, 0, 0); errno != 0 {
+		fail(fmt.Errorf("clearing dumpable: %w", errno))
+	}
+
+	step := exec.Command("/proc/self/exe")
+	step.Args = append([]string{sandboxStep}, os.Args[3:]...)
+	step.Dir = "/work"
+	step.Stdout = os.Stdout
+	step.Stderr = os.Stderr
+	step.ExtraFiles = []*os.File{setupErrors}
+	err = step.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fail(err)
+	}
+
+	// Whatever the step left running in the background would otherwise
+	// keep changing /work while it is copied
+	syscall.Kill(-1, syscall.SIGKILL)
+	for {
+		if _, err := syscall.Wait4(-1, nil, 0, nil); err == syscall.ECHILD {
+			break
+		}
+	}
+
+	if err := clearDir(host); err != nil {
+		fail(fmt.Errorf("clearing the work directory: %w", err))
+	}
+	if err := copyTree(host, os.DirFS("/work")); err != nil {
+		fail(fmt.Errorf("copying /work back: %w", err))
+	}
+
+	// PID 1 can't kill itself with the signal the shell died of, so it
+	// reports it the way shells do
+	status := step.ProcessState.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		os.Exit(128 + int(status.Signal()))
+	}
+	os.Exit(status.ExitStatus())
+}
+
+// stepMain runs in the sandbox as the child of sandboxMain. It drops every
+// privilege the user namespace gave us, applies the limits and replaces
+// itself with the shell. It never returns.
+func stepMain() {
+	// The bounding set is per thread and has to be dropped on the one that
+	// calls exec
+	runtime.LockOSThread()
+
+	setupErrors := os.NewFile(3, "setup errors")
+	var limits [4]uint64
+	for i := range limits {
+		n, err := strconv.ParseUint(os.Args[1+i], 10, 64)
+		if err != nil {
+			fmt.Fprint(setupErrors, err)
+			os.Exit(125)
+		}
+		limits[i] = n
+	}
+
+	err := restrict(limits[0], limits[1], limits[2], limits[3])
+	if err == nil {
+		syscall.CloseOnExec(3)
+		err = syscall.Exec("/bin/sh", []string{"sh", "-c", os.Args[5]}, os.Environ())
+	}
+	fmt.Fprint(setupErrors, err)
+	os.Exit(125)
+}
+
+// enterSandbox builds the new root in dir/root, fills its /work from
+// dir/work and pivots into it. The returned root is dir/work, still
+// reachable from inside for copying /work back.
+func enterSandbox(dir string, workSize, tmpSize uint64) (*os.Root, error) {
+	root := filepath.Join(dir, "root")
+
+	// Nothing mounted from here on may propagate back to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return nil, fmt.Errorf("making mounts private: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=1m,mode=755"); err != nil {
+		return nil, fmt.Errorf("mounting root: %w", err)
+	}
+
+	for _, path := range readOnlyPaths {
+		if err := bindReadOnly(path, root+path); err != nil {
+			return nil, fmt.Errorf("mounting %s: %w", path, err)
+		}
+	}
+
+	// A tmpfs rather than a bind of dir/work, RLIMIT_FSIZE alone would
+	// still let a step fill the host's disk with many files
+	work := filepath.Join(root, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		return nil, err
+	}
+	if err := syscall.Mount("tmpfs", work, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, fmt.Sprintf("size=%d,mode=755", workSize)); err != nil {
+		return nil, fmt.Errorf("mounting /work: %w", err)
+	}
+	host, err := os.OpenRoot(filepath.Join(dir, "work"))
+	if err != nil {
+		return nil, err
+	}
+	sandboxWork, err := os.OpenRoot(work)
+	if err != nil {
+		return nil, err
+	}
+	err = copyTree(sandboxWork, host.FS())
+	sandboxWork.Close()
+	if err != nil {
+		return nil, fmt.Errorf("copying files to /work: %w", err)
+	}
+
+	tmp := filepath.Join(root, "tmp")
+	if err := os.Mkdir(tmp, 0o755); err != nil {
+		return nil, err
+	}
+	if err := syscall.Mount("tmpfs", tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, fmt.Sprintf("size=%d,mode=1777", tmpSize)); err != nil {
+		return nil, fmt.Errorf("mounting /tmp: %w", err)
+	}
+
+	proc := filepath.Join(root, "proc")
+	if err := os.Mkdir(proc, 0o555); err != nil {
+		return nil, err
+	}
+	if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return nil, fmt.Errorf("mounting /proc: %w", err)
+	}
+
+	old := filepath.Join(root, ".old")
+	if err := os.Mkdir(old, 0o700); err != nil {
+		return nil, err
+	}
+	if err := syscall.PivotRoot(root, old); err != nil {
+		return nil, fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return nil, err
+	}
+	if err := syscall.Unmount("/.old", syscall.MNT_DETACH); err != nil {
+		return nil, fmt.Errorf("unmounting the old root: %w", err)
+	}
+	if err := os.Remove("/.old"); err != nil {
+		return nil, err
+	}
+	if err := syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return nil, fmt.Errorf("remounting root read-only: %w", err)
+	}
+	return host, nil
+}
+
+// restrict drops every capability for good and applies the rlimits.
+func restrict(cpuTime, memory, fileSize, processes uint64) error {
+	// A new user namespace starts with an empty inheritable set, so without
+	// a bounding set the shell gets no capabilities when we exec it, and
+	// nothing it runs can gain any
+	for c := uintptr(0); ; c++ {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, c, 0)
+		if errno == syscall.EINVAL {
+			break
+		}
+		if errno != 0 {
+			return fmt.Errorf("dropping capability %d: %w", c, errno)
+		}
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("setting no_new_privs: %w", errno)
+	}
+
+	// Last, so they don't get in the way of the setup. The user namespace
+	// has its own process count, so RLIMIT_NPROC only counts the sandbox.
+	for _, limit := range []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, cpuTime},
+		{syscall.RLIMIT_AS, memory},
+		{syscall.RLIMIT_FSIZE, fileSize},
+		{unix.RLIMIT_NPROC, processes},
+	} {
+		rlimit := syscall.Rlimit{Cur: limit.value, Max: limit.value}
+		if err := syscall.Setrlimit(limit.resource, &rlimit); err != nil {
+			return fmt.Errorf("setting rlimit %d: %w", limit.resource, err)
+		}
+	}
+	return nil
+}
+
+// clearDir removes everything inside root, but not root itself.
+func clearDir(root *os.Root) error {
+	entries, err := fs.ReadDir(root.FS(), ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := root.RemoveAll(entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTree copies directories, regular files and symlinks from src into dst
+// without following any symlink. Anything else, like a named pipe, is
+// skipped.
+func copyTree(dst *os.Root, src fs.FS) error {
+	return fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return dst.Mkdir(name, 0o755)
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := fs.ReadLink(src, name)
+			if err != nil {
+				return err
+			}
+			return dst.Symlink(target, name)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return copyFile(dst, src, name, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(dst *os.Root, src fs.FS, name string, perm fs.FileMode) error {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := dst.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// bindReadOnly makes src visible at dst without allowing writes to it. Only
+// src itself is mounted, not what is mounted below it.
+func bindReadOnly(src, dst string) error {
+	info, err := os.Lstat(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, 0o755); err != nil {
+			return err
+		}
+	default:
+		f, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(src, &stat); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for _, f := range lockedFlags {
+		if uintptr(stat.Flags)&f.statfs != 0 {
+			flags |= f.mount
+		}
+	}
+	return syscall.Mount("", dst, "", flags, "")
+}
@@ -0,0 +1,154 @@
+//go:build linux
+
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRunner returns a runner working in a fresh temporary directory, or
+// skips the test when the sandbox can't be created here, like in containers
+// that forbid user namespaces.
+func testRunner(t *testing.T, limits Limits) *Runner {
+	t.Helper()
+	r := New(limits, 1)
+	r.BaseDir = t.TempDir()
+	// Run as root, the sandbox is nobody and has to reach the work directory
+	if err := os.Chmod(r.BaseDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Dir(r.BaseDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Run(context.Background(), nil, []Step{{Position: 1, Command: "true"}}); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	return r
+}
+
+func run(t *testing.T, r *Runner, files map[string]string, commands ...string) []Result {
+	t.Helper()
+	steps := make([]Step, len(commands))
+	for i, command := range commands {
+		steps[i] = Step{Position: int32(i + 1), Command: command}
+	}
+	results, err := r.Run(context.Background(), files, steps)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return results
+}
+
+func TestRunHasNoNetwork(t *testing.T) {
+	r := testRunner(t, DefaultLimits)
+	results := run(t, r, nil, "cat /proc/net/dev")
+	if results[0].ExitCode != 0 {
+		t.Fatalf("exit code %d, stderr %q", results[0].ExitCode, results[0].Stderr)
+	}
+
+	for _, line := range strings.Split(results[0].Stdout, "\n") {
+		name, _, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(name) != "lo" {
+			t.Errorf("sandbox has network interface %q", strings.TrimSpace(name))
+		}
+	}
+}
+
+func TestRunRootIsReadOnly(t *testing.T) {
+	r := testRunner(t, DefaultLimits)
+	results := run(t, r, nil,
+		"touch /x",
+		"touch /usr/x",
+		"touch /tmp/x && echo ok > out && cat out",
+	)
+
+	for _, result := range results[:2] {
+		if result.ExitCode == 0 {
+			t.Errorf("step %d wrote outside /work and /tmp", result.Position)
+		}
+	}
+	if results[2].ExitCode != 0 || results[2].Stdout != "ok\n" {
+		t.Errorf("step 3: exit code %d, stdout %q, stderr %q", results[2].ExitCode, results[2].Stdout, results[2].Stderr)
+	}
+}
+
+func TestRunKeepsWorkBetweenSteps(t *testing.T) {
+	r := testRunner(t, DefaultLimits)
+	results := run(t, r, map[string]string{"src/main.txt": "hello"},
+		"cp src/main.txt out.txt && chmod +x src && ln -s out.txt link",
+		"cat link",
+	)
+
+	if results[1].ExitCode != 0 || results[1].Stdout != "hello" {
+		t.Errorf("step 2: exit code %d, stdout %q, stderr %q", results[1].ExitCode, results[1].Stdout, results[1].Stderr)
+	}
+}
+
+func TestRunCPULimit(t *testing.T) {
+	limits := DefaultLimits
+	limits.CPUTime = time.Second
+	limits.WallTime = 10 * time.Second
+	r := testRunner(t, limits)
+	results := run(t, r, nil, "while :; do :; done")
+
+	if results[0].TimedOut {
+		t.Fatal("step ran into the wall clock timeout instead of the CPU limit")
+	}
+	if results[0].ExitCode <= 128 {
+		t.Errorf("exit code %d, want one of a killed process", results[0].ExitCode)
+	}
+}
+
+func TestRunMemoryLimit(t *testing.T) {
+	limits := DefaultLimits
+	limits.Memory = 64 << 20
+	r := testRunner(t, limits)
+	results := run(t, r, nil, `x=$(head -c 200000000 /dev/zero | tr '\0' a); echo done`)
+
+	if results[0].ExitCode == 0 || results[0].Stdout != "" {
+		t.Errorf("exit code %d, stdout %q: the shell held 200 MB with a 64 MB limit", results[0].ExitCode, results[0].Stdout)
+	}
+}
+
+func TestRunWorkSizeLimit(t *testing.T) {
+	limits := DefaultLimits
+	limits.WorkSize = 1 << 20
+	r := testRunner(t, limits)
+	results := run(t, r, nil, "for i in 1 2 3 4; do dd if=/dev/zero of=f$i bs=1024 count=512 || exit 1; done")
+
+	if results[0].ExitCode == 0 {
+		t.Error("wrote 2 MB into a 1 MB /work")
+	}
+}
+
+func TestRunProcessLimit(t *testing.T) {
+	limits := DefaultLimits
+	limits.Processes = 16
+	r := testRunner(t, limits)
+	results := run(t, r, nil, "for i in $(seq 32); do sleep 1 & done; wait")
+
+	if results[0].ExitCode == 0 {
+		t.Error("started 32 processes with a limit of 16")
+	}
+}
+
+func TestRunRemovesWorkDirectory(t *testing.T) {
+	r := testRunner(t, DefaultLimits)
+	run(t, r, map[string]string{"main.txt": "hello"}, "echo hi > out", "sleep 30 &")
+	if _, err := r.Run(context.Background(), map[string]string{"../escape": ""}, nil); err != ErrInvalidFile {
+		t.Errorf("Run with an escaping file name: got %v, want ErrInvalidFile", err)
+	}
+
+	entries, err := os.ReadDir(r.BaseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("%s left behind", entry.Name())
+	}
+}
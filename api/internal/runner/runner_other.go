@@ -0,0 +1,14 @@
+//go:build !linux
+
+package runner
+
+import (
+	"context"
+	"io"
+)
+
+const supported = false
+
+func sandboxRun(ctx context.Context, dir, command string, limits Limits, stdout, stderr io.Writer) error {
+	panic(ErrUnsupported)
+}
@@ -1,23 +1,27 @@
 -- name: CreateCourse :one
-INSERT INTO courses (id, created_at, updated_at, title, description)
+INSERT INTO courses (id, created_at, updated_at, title, description, slug, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
 RETURNING *;
 
 -- name: GetCourses :many
 SELECT * FROM courses
+WHERE deleted_at IS NULL
 ORDER BY created_at DESC;
 
 -- name: GetCourse :one
-SELECT * FROM courses WHERE id = $1;
+SELECT * FROM courses WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: CreateLesson :one
-INSERT INTO lessons (id, created_at, updated_at, course_id, title, content, "position")
+INSERT INTO lessons (id, created_at, updated_at, course_id, title, content, "position", slug, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
@@ -25,49 +29,62 @@ VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6,
+    $7
 )
 RETURNING *;
 
 -- name: GetLessonsByCourseID :many
 SELECT * FROM lessons 
-WHERE course_id = $1 
+WHERE course_id = $1 AND deleted_at IS NULL
 ORDER BY "position" ASC;
 
 -- name: GetLessonsWithStatus :many
-SELECT 
-    l.id, 
-    l.title, 
+-- A lesson is completed once all of its tasks are, or, if it has none, once
+-- it was marked read.
+SELECT
+    l.id,
+    l.title,
     l.position,
     l.course_id,
-    CASE 
-        WHEN tc.task_id IS NOT NULL THEN true 
-        ELSE false 
-    END as is_completed
+    l.status,
+    l.publish_at,
+    COUNT(t.id) AS task_count,
+    CASE
+        WHEN COUNT(t.id) = 0 THEN EXISTS (
+            SELECT 1 FROM lesson_reads lr
+            WHERE lr.lesson_id = l.id AND lr.user_id = $2
+        )
+        ELSE COUNT(t.id) = COUNT(tc.task_id)
+    END::boolean AS is_completed
 FROM lessons l
-LEFT JOIN tasks t ON t.lesson_id = l.id  -- <--- CHANGE TO 'LEFT JOIN'
-LEFT JOIN task_completions tc 
-    ON tc.task_id = t.id 
+LEFT JOIN tasks t ON t.lesson_id = l.id AND t.deleted_at IS NULL
+LEFT JOIN task_completions tc
+    ON tc.task_id = t.id
     AND tc.user_id = $2
-WHERE l.course_id = $1
+WHERE l.course_id = $1 AND l.deleted_at IS NULL
+GROUP BY l.id
 ORDER BY l.position;
 
 -- name: GetLesson :one
-SELECT * FROM lessons WHERE id = $1;
+SELECT * FROM lessons WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: CreateTask :one
-INSERT INTO tasks (id, created_at, updated_at, lesson_id, description)
+INSERT INTO tasks (id, created_at, updated_at, lesson_id, description, position)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
 -- name: CreateTaskStep :one
-INSERT INTO task_steps (id, created_at, updated_at, task_id, position, command, expected_output)
+INSERT INTO task_steps (id, created_at, updated_at, task_id, position, command, expected_output, match_mode, tolerance)
 VALUES (
     gen_random_uuid(),
     NOW(),
@@ -75,18 +92,32 @@ VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
--- name: GetTaskByLessonID :one
-SELECT * FROM tasks WHERE lesson_id = $1;
+-- name: GetTask :one
+SELECT * FROM tasks WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetTasksByLessonID :many
+SELECT * FROM tasks
+WHERE lesson_id = $1 AND deleted_at IS NULL
+ORDER BY position ASC;
 
 -- name: GetStepsByTaskID :many
 SELECT * FROM task_steps 
-WHERE task_id = $1 
+WHERE task_id = $1 AND deleted_at IS NULL
 ORDER BY position ASC;
 
+-- name: GetTaskStep :one
+SELECT * FROM task_steps WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: LockTask :exec
+-- Holds back other changes to the task and its steps until the transaction ends.
+SELECT id FROM tasks WHERE id = $1 FOR UPDATE;
+
 -- name: CompleteTask :exec
 INSERT INTO task_completions (id, created_at, updated_at, user_id, task_id)
 VALUES (
@@ -98,11 +129,143 @@ VALUES (
 )
 ON CONFLICT (user_id, task_id) DO NOTHING;
 
--- name: DeleteCourse :exec
-DELETE FROM courses WHERE id = $1;
+-- name: MarkLessonRead :exec
+INSERT INTO lesson_reads (id, created_at, user_id, lesson_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1, -- User ID
+    $2  -- Lesson ID
+)
+ON CONFLICT (user_id, lesson_id) DO NOTHING;
+
+-- name: GetTaskCompletionsByUserID :many
+SELECT * FROM task_completions
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetLessonReadsByUserID :many
+SELECT * FROM lesson_reads
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UpdateCourse :one
+UPDATE courses
+SET title = COALESCE(sqlc.narg('title'), title),
+    description = COALESCE(sqlc.narg('description'), description),
+    slug = COALESCE(sqlc.narg('slug'), slug),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateLesson :one
+UPDATE lessons
+SET title = COALESCE(sqlc.narg('title'), title),
+    content = COALESCE(sqlc.narg('content'), content),
+    "position" = COALESCE(sqlc.narg('position'), "position"),
+    slug = COALESCE(sqlc.narg('slug'), slug),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateTask :one
+UPDATE tasks
+SET description = COALESCE(sqlc.narg('description'), description),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateTaskStep :one
+UPDATE task_steps
+SET command = COALESCE(sqlc.narg('command'), command),
+    expected_output = COALESCE(sqlc.narg('expected_output'), expected_output),
+    position = COALESCE(sqlc.narg('position'), position),
+    match_mode = COALESCE(sqlc.narg('match_mode'), match_mode),
+    tolerance = COALESCE(sqlc.narg('tolerance'), tolerance),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeferPositionConstraints :exec
+-- Lets a transaction move positions through each other, see ReorderLessons.
+SET CONSTRAINTS unique_course_lesson_position, unique_lesson_task_position, unique_task_step_position DEFERRED;
+
+-- name: SetLessonPosition :execrows
+UPDATE lessons
+SET "position" = $3,
+    updated_at = NOW()
+WHERE id = $1 AND course_id = $2 AND deleted_at IS NULL;
+
+-- name: SetTaskPosition :execrows
+UPDATE tasks
+SET position = $3,
+    updated_at = NOW()
+WHERE id = $1 AND lesson_id = $2 AND deleted_at IS NULL;
+
+-- name: SetTaskStepPosition :execrows
+UPDATE task_steps
+SET position = $3,
+    updated_at = NOW()
+WHERE id = $1 AND task_id = $2 AND deleted_at IS NULL;
+
+-- name: ShiftLessonPositions :exec
+-- Makes room for a lesson right after the given position.
+UPDATE lessons
+SET "position" = "position" + 1,
+    updated_at = NOW()
+WHERE course_id = $1 AND "position" > $2 AND deleted_at IS NULL;
 
--- name: DeleteLesson :exec
-DELETE FROM lessons WHERE id = $1;
+-- name: ShiftTaskPositions :exec
+-- Makes room for a task right after the given position.
+UPDATE tasks
+SET position = position + 1,
+    updated_at = NOW()
+WHERE lesson_id = $1 AND position > $2 AND deleted_at IS NULL;
 
--- name: DeleteTask :exec
-DELETE FROM tasks WHERE id = $1;
+-- name: ShiftTaskStepPositions :exec
+-- Makes room for a step right after the given position.
+UPDATE task_steps
+SET position = position + 1,
+    updated_at = NOW()
+WHERE task_id = $1 AND position > $2 AND deleted_at IS NULL;
+
+-- name: GetNextLessonPosition :one
+SELECT (COALESCE(MAX("position"), 0) + 1)::int AS next_position
+FROM lessons
+WHERE course_id = $1 AND deleted_at IS NULL;
+
+-- name: GetNextTaskPosition :one
+SELECT (COALESCE(MAX(position), 0) + 1)::int AS next_position
+FROM tasks
+WHERE lesson_id = $1 AND deleted_at IS NULL;
+
+-- name: GetNextTaskStepPosition :one
+SELECT (COALESCE(MAX(position), 0) + 1)::int AS next_position
+FROM task_steps
+WHERE task_id = $1 AND deleted_at IS NULL;
+
+-- name: SetCourseStatus :one
+UPDATE courses
+SET status = $2,
+    publish_at = $3,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: SetLessonStatus :one
+UPDATE lessons
+SET status = $2,
+    publish_at = $3,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetLessonVisibility :one
+SELECT
+    l.status AS lesson_status,
+    l.publish_at AS lesson_publish_at,
+    c.status AS course_status,
+    c.publish_at AS course_publish_at
+FROM lessons l
+JOIN courses c ON c.id = l.course_id
+WHERE l.id = $1 AND l.deleted_at IS NULL;
@@ -0,0 +1,51 @@
+-- name: CreateDeviceCode :one
+INSERT INTO device_codes (id, created_at, device_code_hash, user_code, client_name, scopes, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetDeviceCodeByHash :one
+SELECT * FROM device_codes WHERE device_code_hash = $1;
+
+-- name: GetPendingDeviceCode :one
+SELECT * FROM device_codes
+WHERE user_code = $1
+  AND approved_at IS NULL
+  AND denied_at IS NULL
+  AND expires_at > NOW();
+
+-- name: ApproveDeviceCode :execrows
+UPDATE device_codes
+SET user_id = $2, approved_at = NOW()
+WHERE user_code = $1
+  AND approved_at IS NULL
+  AND denied_at IS NULL
+  AND expires_at > NOW();
+
+-- name: DenyDeviceCode :execrows
+UPDATE device_codes
+SET user_id = $2, denied_at = NOW()
+WHERE user_code = $1
+  AND approved_at IS NULL
+  AND denied_at IS NULL
+  AND expires_at > NOW();
+
+-- name: TouchDeviceCode :exec
+UPDATE device_codes SET last_polled_at = NOW() WHERE id = $1;
+
+-- name: DeleteDeviceCode :execrows
+DELETE FROM device_codes WHERE id = $1;
+
+-- name: DeleteExpiredDeviceCodes :exec
+DELETE FROM device_codes WHERE expires_at < NOW();
+
+-- name: DeleteUserDeviceCodes :exec
+-- Approved codes that were not picked up yet would still turn into API keys.
+DELETE FROM device_codes WHERE user_id = $1;
@@ -0,0 +1,48 @@
+-- name: CreateOIDCLogin :exec
+INSERT INTO oidc_logins (state_hash, created_at, provider, code_verifier, nonce, user_id, reauth, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: ConsumeOIDCLogin :one
+-- A login can only be finished once.
+DELETE FROM oidc_logins
+WHERE state_hash = $1
+  AND provider = $2
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLogins :exec
+DELETE FROM oidc_logins WHERE expires_at <= NOW();
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, user_id, provider, subject, email)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetUserByIdentity :one
+SELECT users.* FROM users
+JOIN user_identities i ON i.user_id = users.id
+WHERE i.provider = $1 AND i.subject = $2;
+
+-- name: GetUserIdentitiesByUserID :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities WHERE id = $1 AND user_id = $2;
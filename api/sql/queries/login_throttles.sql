@@ -0,0 +1,26 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles WHERE throttle_key = $1;
+
+-- name: RecordLoginFailure :one
+-- Failures older than a day are forgotten and counting starts over
+INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (throttle_key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < NOW() - INTERVAL '1 day' THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: SetLoginLockout :exec
+UPDATE login_throttles SET locked_until = $2 WHERE throttle_key = $1;
+
+-- name: ClearLoginThrottle :execrows
+DELETE FROM login_throttles WHERE throttle_key = $1;
+
+-- name: ListLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE last_failure_at > NOW() - INTERVAL '1 day'
+   OR locked_until > NOW()
+ORDER BY last_failure_at DESC;
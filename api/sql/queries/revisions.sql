@@ -0,0 +1,32 @@
+-- name: CreateRevision :one
+-- Write the row first: its lock keeps concurrent edits from picking the same version.
+INSERT INTO content_revisions (id, created_at, entity_type, entity_id, version, action, author_id, snapshot)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    (SELECT COALESCE(MAX(version), 0) + 1 FROM content_revisions WHERE entity_type = $1 AND entity_id = $2),
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetRevision :one
+SELECT * FROM content_revisions
+WHERE entity_type = $1 AND entity_id = $2 AND version = $3;
+
+-- name: GetRevisions :many
+-- Newest first, without the snapshots.
+SELECT
+    r.id,
+    r.created_at,
+    r.version,
+    r.action,
+    r.author_id,
+    COALESCE(u.username, '') AS author
+FROM content_revisions r
+LEFT JOIN users u ON u.id = r.author_id
+WHERE r.entity_type = $1 AND r.entity_id = $2
+ORDER BY r.version DESC;
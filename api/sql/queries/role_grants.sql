@@ -0,0 +1,34 @@
+-- name: CreateRoleGrant :one
+INSERT INTO role_grants (id, created_at, user_id, role, course_id, granted_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetRoleGrantsByUserID :many
+SELECT * FROM role_grants
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteRoleGrant :execrows
+DELETE FROM role_grants WHERE id = $1;
+
+-- name: GetCourseIDByLessonID :one
+-- Like the other lookups it ignores the trash, so trashed rows can be restored.
+SELECT course_id FROM lessons WHERE id = $1;
+
+-- name: GetCourseIDByTaskID :one
+SELECT l.course_id FROM tasks t
+JOIN lessons l ON l.id = t.lesson_id
+WHERE t.id = $1;
+
+-- name: GetCourseIDByStepID :one
+SELECT l.course_id FROM task_steps s
+JOIN tasks t ON t.id = s.task_id
+JOIN lessons l ON l.id = t.lesson_id
+WHERE s.id = $1;
@@ -0,0 +1,45 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, updated_at, user_id, refresh_token_hash, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetUserBySession :one
+SELECT users.* FROM users
+JOIN sessions ON sessions.user_id = users.id
+WHERE sessions.id = $1
+  AND sessions.user_id = $2
+  AND sessions.revoked_at IS NULL
+  AND sessions.expires_at > NOW();
+
+-- name: RotateSession :one
+UPDATE sessions
+SET refresh_token_hash = sqlc.arg(new_token_hash),
+    previous_token_hash = refresh_token_hash,
+    expires_at = sqlc.arg(expires_at),
+    updated_at = NOW()
+WHERE refresh_token_hash = sqlc.arg(old_token_hash)
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeSessionByPreviousToken :execrows
+UPDATE sessions
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE previous_token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeSessionByToken :execrows
+UPDATE sessions
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE refresh_token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
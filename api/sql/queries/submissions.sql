@@ -0,0 +1,37 @@
+-- name: CreateSubmission :one
+INSERT INTO submissions (id, created_at, user_id, task_id, passed, results)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetSubmissionsByUserAndTask :many
+SELECT * FROM submissions
+WHERE user_id = $1 AND task_id = $2
+ORDER BY created_at DESC;
+
+-- name: GetSubmissionsByLessonID :many
+SELECT
+    s.id,
+    s.created_at,
+    s.user_id,
+    u.username,
+    s.task_id,
+    s.passed,
+    s.results
+FROM submissions s
+JOIN tasks t ON t.id = s.task_id
+JOIN users u ON u.id = s.user_id
+WHERE t.lesson_id = $1
+ORDER BY s.created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetSubmissionsByUserID :many
+SELECT * FROM submissions
+WHERE user_id = $1
+ORDER BY created_at;
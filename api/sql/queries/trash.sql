@@ -0,0 +1,214 @@
+-- name: TrashCourse :one
+-- Everything deleted along with the course gets the same deleted_at. No row when the course is not live.
+WITH course AS (
+    UPDATE courses SET deleted_at = NOW()
+    WHERE id = $1 AND deleted_at IS NULL
+    RETURNING *
+), lesson AS (
+    UPDATE lessons SET deleted_at = NOW()
+    WHERE course_id IN (SELECT id FROM course) AND deleted_at IS NULL
+    RETURNING id
+), task AS (
+    UPDATE tasks SET deleted_at = NOW()
+    WHERE lesson_id IN (SELECT id FROM lesson) AND deleted_at IS NULL
+    RETURNING id
+), step AS (
+    UPDATE task_steps SET deleted_at = NOW()
+    WHERE task_id IN (SELECT id FROM task) AND deleted_at IS NULL
+)
+SELECT * FROM course;
+
+-- name: TrashLesson :one
+WITH lesson AS (
+    UPDATE lessons SET deleted_at = NOW()
+    WHERE id = $1 AND deleted_at IS NULL
+    RETURNING *
+), task AS (
+    UPDATE tasks SET deleted_at = NOW()
+    WHERE lesson_id IN (SELECT id FROM lesson) AND deleted_at IS NULL
+    RETURNING id
+), step AS (
+    UPDATE task_steps SET deleted_at = NOW()
+    WHERE task_id IN (SELECT id FROM task) AND deleted_at IS NULL
+)
+SELECT * FROM lesson;
+
+-- name: TrashTask :one
+WITH task AS (
+    UPDATE tasks SET deleted_at = NOW()
+    WHERE id = $1 AND deleted_at IS NULL
+    RETURNING *
+), step AS (
+    UPDATE task_steps SET deleted_at = NOW()
+    WHERE task_id IN (SELECT id FROM task) AND deleted_at IS NULL
+)
+SELECT * FROM task;
+
+-- name: TrashTaskStep :one
+UPDATE task_steps SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreCourse :one
+-- Brings back what was deleted along with the course, not what was deleted before it.
+WITH trashed AS (
+    SELECT id, deleted_at FROM courses
+    WHERE id = $1 AND deleted_at IS NOT NULL
+), lesson AS (
+    UPDATE lessons l SET deleted_at = NULL
+    FROM trashed
+    WHERE l.course_id = trashed.id AND l.deleted_at = trashed.deleted_at
+    RETURNING l.id
+), task AS (
+    UPDATE tasks t SET deleted_at = NULL
+    FROM trashed
+    WHERE t.lesson_id IN (SELECT id FROM lesson) AND t.deleted_at = trashed.deleted_at
+    RETURNING t.id
+), step AS (
+    UPDATE task_steps s SET deleted_at = NULL
+    FROM trashed
+    WHERE s.task_id IN (SELECT id FROM task) AND s.deleted_at = trashed.deleted_at
+)
+UPDATE courses c
+SET deleted_at = NULL,
+    updated_at = NOW()
+FROM trashed
+WHERE c.id = trashed.id
+RETURNING c.*;
+
+-- name: RestoreLesson :one
+-- Needs the course out of the trash. If the position was taken meanwhile the lesson goes to the end.
+WITH trashed AS (
+    SELECT l.id, l.deleted_at FROM lessons l
+    JOIN courses c ON c.id = l.course_id
+    WHERE l.id = $1 AND l.deleted_at IS NOT NULL AND c.deleted_at IS NULL
+), task AS (
+    UPDATE tasks t SET deleted_at = NULL
+    FROM trashed
+    WHERE t.lesson_id = trashed.id AND t.deleted_at = trashed.deleted_at
+    RETURNING t.id
+), step AS (
+    UPDATE task_steps s SET deleted_at = NULL
+    FROM trashed
+    WHERE s.task_id IN (SELECT id FROM task) AND s.deleted_at = trashed.deleted_at
+)
+UPDATE lessons l
+SET deleted_at = NULL,
+    "position" = CASE
+        WHEN EXISTS (SELECT 1 FROM lessons o WHERE o.course_id = l.course_id AND o."position" = l."position" AND o.deleted_at IS NULL)
+        THEN (SELECT COALESCE(MAX(o."position"), 0) + 1 FROM lessons o WHERE o.course_id = l.course_id AND o.deleted_at IS NULL)
+        ELSE l."position"
+    END,
+    updated_at = NOW()
+FROM trashed
+WHERE l.id = trashed.id
+RETURNING l.*;
+
+-- name: RestoreTask :one
+-- Needs the lesson out of the trash. If the position was taken meanwhile the task goes to the end.
+WITH trashed AS (
+    SELECT t.id, t.deleted_at FROM tasks t
+    JOIN lessons l ON l.id = t.lesson_id
+    WHERE t.id = $1 AND t.deleted_at IS NOT NULL AND l.deleted_at IS NULL
+), step AS (
+    UPDATE task_steps s SET deleted_at = NULL
+    FROM trashed
+    WHERE s.task_id = trashed.id AND s.deleted_at = trashed.deleted_at
+)
+UPDATE tasks t
+SET deleted_at = NULL,
+    position = CASE
+        WHEN EXISTS (SELECT 1 FROM tasks o WHERE o.lesson_id = t.lesson_id AND o.position = t.position AND o.deleted_at IS NULL)
+        THEN (SELECT COALESCE(MAX(o.position), 0) + 1 FROM tasks o WHERE o.lesson_id = t.lesson_id AND o.deleted_at IS NULL)
+        ELSE t.position
+    END,
+    updated_at = NOW()
+FROM trashed
+WHERE t.id = trashed.id
+RETURNING t.*;
+
+-- name: RestoreTaskStep :one
+-- Needs the task out of the trash. If the position was taken meanwhile the step goes to the end.
+UPDATE task_steps s
+SET deleted_at = NULL,
+    position = CASE
+        WHEN EXISTS (SELECT 1 FROM task_steps o WHERE o.task_id = s.task_id AND o.position = s.position AND o.deleted_at IS NULL)
+        THEN (SELECT COALESCE(MAX(o.position), 0) + 1 FROM task_steps o WHERE o.task_id = s.task_id AND o.deleted_at IS NULL)
+        ELSE s.position
+    END,
+    updated_at = NOW()
+FROM tasks t
+WHERE s.id = $1 AND s.deleted_at IS NOT NULL AND t.id = s.task_id AND t.deleted_at IS NULL
+RETURNING s.*;
+
+-- name: GetTrash :many
+-- Only what was deleted directly, newest first. Rows deleted along with their parent come back with it.
+SELECT entity_type, id, title, course_id, deleted_at
+FROM (
+    SELECT 'course'::text AS entity_type, c.id, c.title, c.id AS course_id, c.deleted_at
+    FROM courses c
+    WHERE c.deleted_at IS NOT NULL
+    UNION ALL
+    SELECT 'lesson', l.id, l.title, l.course_id, l.deleted_at
+    FROM lessons l
+    JOIN courses c ON c.id = l.course_id
+    WHERE l.deleted_at IS NOT NULL AND c.deleted_at IS DISTINCT FROM l.deleted_at
+    UNION ALL
+    SELECT 'task', t.id, t.description, l.course_id, t.deleted_at
+    FROM tasks t
+    JOIN lessons l ON l.id = t.lesson_id
+    WHERE t.deleted_at IS NOT NULL AND l.deleted_at IS DISTINCT FROM t.deleted_at
+    UNION ALL
+    SELECT 'task_step', s.id, s.command, l.course_id, s.deleted_at
+    FROM task_steps s
+    JOIN tasks t ON t.id = s.task_id
+    JOIN lessons l ON l.id = t.lesson_id
+    WHERE s.deleted_at IS NOT NULL AND t.deleted_at IS DISTINCT FROM s.deleted_at
+) trash
+WHERE sqlc.narg('course_id')::uuid IS NULL OR course_id = sqlc.narg('course_id')
+ORDER BY deleted_at DESC;
+
+-- name: PurgeTaskSteps :one
+-- Deletes steps that have been in the trash for more than the given days, with their revisions.
+WITH purged AS (
+    DELETE FROM task_steps
+    WHERE deleted_at < NOW() - sqlc.arg('days')::int * INTERVAL '1 day'
+    RETURNING id
+), history AS (
+    DELETE FROM content_revisions
+    WHERE entity_type = 'task_step' AND entity_id IN (SELECT id FROM purged)
+)
+SELECT COUNT(*) FROM purged;
+
+-- name: PurgeTasks :one
+WITH purged AS (
+    DELETE FROM tasks
+    WHERE deleted_at < NOW() - sqlc.arg('days')::int * INTERVAL '1 day'
+    RETURNING id
+), history AS (
+    DELETE FROM content_revisions
+    WHERE entity_type = 'task' AND entity_id IN (SELECT id FROM purged)
+)
+SELECT COUNT(*) FROM purged;
+
+-- name: PurgeLessons :one
+WITH purged AS (
+    DELETE FROM lessons
+    WHERE deleted_at < NOW() - sqlc.arg('days')::int * INTERVAL '1 day'
+    RETURNING id
+), history AS (
+    DELETE FROM content_revisions
+    WHERE entity_type = 'lesson' AND entity_id IN (SELECT id FROM purged)
+)
+SELECT COUNT(*) FROM purged;
+
+-- name: PurgeCourses :one
+WITH purged AS (
+    DELETE FROM courses
+    WHERE deleted_at < NOW() - sqlc.arg('days')::int * INTERVAL '1 day'
+    RETURNING id
+), history AS (
+    DELETE FROM content_revisions
+    WHERE entity_type = 'course' AND entity_id IN (SELECT id FROM purged)
+)
+SELECT COUNT(*) FROM purged;
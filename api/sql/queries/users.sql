@@ -16,11 +16,84 @@ SELECT * FROM users WHERE username = $1;
 -- name: GetUserByID :one
 SELECT * FROM users WHERE id = $1;
 
--- name: GetUserByAPIKey :one
-SELECT * FROM users WHERE api_key = $1;
-
--- name: UpdateAPIKey :one
-UPDATE users 
-SET api_key = $2
-WHERE id = $1 
-RETURNING api_key;
+-- name: GetUserByEmail :one
+SELECT * FROM users WHERE email = $1;
+
+-- name: SetEmailVerified :exec
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email_verified_at IS NULL;
+
+-- name: UpdatePassword :exec
+-- Choosing a new password completes a forced password reset.
+UPDATE users
+SET password_hash = $2, password_reset_required = FALSE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RequirePasswordReset :exec
+UPDATE users
+SET password_reset_required = TRUE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListUsers :many
+-- A plain substring search, % and _ in it are no wildcards.
+SELECT * FROM users
+WHERE sqlc.arg(search)::text = ''
+    OR strpos(lower(username), lower(sqlc.arg(search))) > 0
+    OR strpos(lower(email), lower(sqlc.arg(search))) > 0
+ORDER BY created_at
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE sqlc.arg(search)::text = ''
+    OR strpos(lower(username), lower(sqlc.arg(search))) > 0
+    OR strpos(lower(email), lower(sqlc.arg(search))) > 0;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DisableUser :one
+UPDATE users
+SET disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableUser :one
+UPDATE users
+SET disabled_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = $1;
+
+-- name: GetUserProgress :many
+-- Completed tasks per course, counting only lessons that have a task.
+SELECT
+    c.id AS course_id,
+    c.title,
+    COUNT(t.id) AS total_tasks,
+    COUNT(tc.id) AS completed_tasks
+FROM courses c
+JOIN lessons l ON l.course_id = c.id
+JOIN tasks t ON t.lesson_id = l.id
+LEFT JOIN task_completions tc
+    ON tc.task_id = t.id
+    AND tc.user_id = $1
+WHERE c.deleted_at IS NULL AND l.deleted_at IS NULL AND t.deleted_at IS NULL
+GROUP BY c.id, c.title
+ORDER BY c.title;
+
+-- name: UpdateUserProfile :one
+-- A new email address has to be verified again.
+UPDATE users
+SET username = $2,
+    email = $3,
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,57 @@
+-- +goose Up
+-- Lessons may already have several tasks. The oldest one is kept and
+-- inherits the completions of the others, which are deleted with their steps.
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY lesson_id ORDER BY created_at, id) AS keep_id
+    FROM tasks
+)
+INSERT INTO task_completions (id, created_at, updated_at, user_id, task_id)
+SELECT gen_random_uuid(), MIN(tc.created_at), NOW(), tc.user_id, ranked.keep_id
+FROM task_completions tc
+JOIN ranked ON ranked.id = tc.task_id
+WHERE ranked.id <> ranked.keep_id
+GROUP BY tc.user_id, ranked.keep_id
+ON CONFLICT (user_id, task_id) DO NOTHING;
+
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY lesson_id ORDER BY created_at, id) AS keep_id
+    FROM tasks
+)
+DELETE FROM tasks USING ranked
+WHERE tasks.id = ranked.id AND ranked.id <> ranked.keep_id;
+
+-- Tasks without a description take the title of their lesson, or a
+-- placeholder when that is blank too
+UPDATE tasks
+SET description = COALESCE(NULLIF(btrim(lessons.title), ''), 'Untitled task'), updated_at = NOW()
+FROM lessons
+WHERE tasks.lesson_id = lessons.id AND btrim(tasks.description) = '';
+
+ALTER TABLE tasks ADD CONSTRAINT unique_lesson_task UNIQUE (lesson_id);
+ALTER TABLE tasks ADD CONSTRAINT tasks_description_not_blank CHECK (btrim(description) <> '');
+
+-- A blank command printed nothing and succeeded, true does the same and
+-- passes the check below. Deleting the steps could leave tasks without any.
+UPDATE task_steps SET command = 'true', updated_at = NOW() WHERE btrim(command) = '';
+
+-- Steps are numbered 1, 2, 3, ... in their current order, which also
+-- resolves duplicate and non-positive positions
+UPDATE task_steps
+SET position = numbered.n
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY task_id ORDER BY position, created_at, id) AS n
+    FROM task_steps
+) numbered
+WHERE task_steps.id = numbered.id AND task_steps.position <> numbered.n;
+
+ALTER TABLE task_steps ADD CONSTRAINT unique_task_step_position UNIQUE (task_id, position);
+ALTER TABLE task_steps ADD CONSTRAINT task_steps_position_positive CHECK (position > 0);
+ALTER TABLE task_steps ADD CONSTRAINT task_steps_command_not_blank CHECK (btrim(command) <> '');
+
+-- +goose Down
+ALTER TABLE task_steps DROP CONSTRAINT IF EXISTS task_steps_command_not_blank;
+ALTER TABLE task_steps DROP CONSTRAINT IF EXISTS task_steps_position_positive;
+ALTER TABLE task_steps DROP CONSTRAINT IF EXISTS unique_task_step_position;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_description_not_blank;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS unique_lesson_task;
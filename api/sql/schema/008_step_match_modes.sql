@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE task_steps
+ADD COLUMN match_mode TEXT NOT NULL DEFAULT 'trimmed',
+ADD COLUMN tolerance DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE task_steps ADD CONSTRAINT task_steps_match_mode_valid
+    CHECK (match_mode IN ('exact', 'trimmed', 'regex', 'contains', 'json', 'numeric'));
+ALTER TABLE task_steps ADD CONSTRAINT task_steps_tolerance_not_negative CHECK (tolerance >= 0);
+
+-- +goose Down
+ALTER TABLE task_steps DROP CONSTRAINT IF EXISTS task_steps_tolerance_not_negative;
+ALTER TABLE task_steps DROP CONSTRAINT IF EXISTS task_steps_match_mode_valid;
+
+ALTER TABLE task_steps
+DROP COLUMN tolerance,
+DROP COLUMN match_mode;
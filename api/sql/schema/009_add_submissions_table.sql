@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE submissions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    passed BOOLEAN NOT NULL,
+    results JSONB NOT NULL -- Per-step outputs and pass/fail, as returned to the client
+);
+
+CREATE INDEX submissions_user_task_idx ON submissions (user_id, task_id, created_at DESC);
+CREATE INDEX submissions_task_idx ON submissions (task_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE submissions;
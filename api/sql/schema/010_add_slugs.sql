@@ -0,0 +1,45 @@
+-- +goose Up
+ALTER TABLE courses ADD COLUMN slug TEXT;
+ALTER TABLE lessons ADD COLUMN slug TEXT;
+
+-- Derive slugs from titles. Duplicates (e.g. from seeding twice) get a
+-- numeric suffix, the oldest row keeps the plain slug.
+WITH ranked AS (
+    SELECT
+        id,
+        COALESCE(NULLIF(trim(both '-' from regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g')), ''), 'untitled') AS base,
+        row_number() OVER (
+            PARTITION BY COALESCE(NULLIF(trim(both '-' from regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g')), ''), 'untitled')
+            ORDER BY created_at, id
+        ) AS n
+    FROM courses
+)
+UPDATE courses c
+SET slug = CASE WHEN r.n = 1 THEN r.base ELSE r.base || '-' || r.n END
+FROM ranked r
+WHERE r.id = c.id;
+
+WITH ranked AS (
+    SELECT
+        id,
+        COALESCE(NULLIF(trim(both '-' from regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g')), ''), 'untitled') AS base,
+        row_number() OVER (
+            PARTITION BY course_id, COALESCE(NULLIF(trim(both '-' from regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g')), ''), 'untitled')
+            ORDER BY "position", id
+        ) AS n
+    FROM lessons
+)
+UPDATE lessons l
+SET slug = CASE WHEN r.n = 1 THEN r.base ELSE r.base || '-' || r.n END
+FROM ranked r
+WHERE r.id = l.id;
+
+ALTER TABLE courses ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE courses ADD CONSTRAINT unique_course_slug UNIQUE (slug);
+
+ALTER TABLE lessons ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE lessons ADD CONSTRAINT unique_course_lesson_slug UNIQUE (course_id, slug);
+
+-- +goose Down
+ALTER TABLE lessons DROP COLUMN slug;
+ALTER TABLE courses DROP COLUMN slug;
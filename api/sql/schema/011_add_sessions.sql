@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the current refresh token
+    previous_token_hash TEXT,                -- The token it replaced, to detect reuse
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id);
+CREATE INDEX sessions_previous_token_idx ON sessions (previous_token_hash);
+
+-- +goose Down
+DROP TABLE sessions;
@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,            -- Start of the key, shown in listings
+    key_hash TEXT NOT NULL UNIQUE,   -- SHA-256 of the key, the key itself is never stored
+    scopes TEXT NOT NULL,            -- Space separated, e.g. 'read-content submit'
+    last_used_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_idx ON api_keys (user_id);
+
+-- Keep existing CLI logins working
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash, scopes)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    id,
+    'default',
+    left(api_key, 8),
+    encode(sha256(convert_to(api_key, 'UTF8')), 'hex'),
+    'read-content submit'
+FROM users
+WHERE api_key IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN api_key;
+
+-- +goose Down
+-- Hashed keys cannot be restored, users have to generate a new one
+ALTER TABLE users ADD COLUMN api_key TEXT UNIQUE;
+DROP TABLE api_keys;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE device_codes (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    device_code_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the code the CLI polls with
+    user_code TEXT NOT NULL UNIQUE,        -- Short code the user types in the browser
+    client_name TEXT NOT NULL,
+    scopes TEXT NOT NULL,                  -- Scopes of the API key handed out on approval
+    expires_at TIMESTAMP NOT NULL,
+    last_polled_at TIMESTAMP,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- Set once approved or denied
+    approved_at TIMESTAMP,
+    denied_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE device_codes;
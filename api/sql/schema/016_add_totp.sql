@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE totp_credentials (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    secret TEXT NOT NULL,                  -- Base32, as shown to the authenticator app
+    enabled_at TIMESTAMP,                  -- NULL until the first code is confirmed
+    last_used_step BIGINT NOT NULL DEFAULT 0 -- Codes of this time step or earlier are rejected
+);
+
+CREATE TABLE recovery_codes (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    CONSTRAINT unique_recovery_code UNIQUE (user_id, code_hash)
+);
+
+-- +goose Down
+DROP TABLE recovery_codes;
+DROP TABLE totp_credentials;
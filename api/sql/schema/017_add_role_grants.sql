@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE role_grants (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('instructor', 'ta', 'content-editor')),
+    course_id UUID REFERENCES courses(id) ON DELETE CASCADE, -- NULL grants the role on every course
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT unique_role_grant UNIQUE NULLS NOT DISTINCT (user_id, role, course_id)
+);
+
+CREATE INDEX role_grants_user_idx ON role_grants (user_id);
+
+-- +goose Down
+DROP TABLE role_grants;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP; -- Set while an admin has disabled the account
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN disabled_at;
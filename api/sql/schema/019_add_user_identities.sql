@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL, -- Name from OIDC_PROVIDERS
+    subject TEXT NOT NULL,  -- The provider's ID for the user
+    email TEXT NOT NULL,
+    CONSTRAINT unique_identity UNIQUE (provider, subject),
+    CONSTRAINT unique_user_provider UNIQUE (user_id, provider)
+);
+
+CREATE TABLE oidc_logins (
+    state_hash TEXT PRIMARY KEY, -- SHA-256 of the state parameter
+    created_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- Set when linking to a logged-in user
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_logins;
+DROP TABLE user_identities;
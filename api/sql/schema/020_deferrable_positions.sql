@@ -0,0 +1,18 @@
+-- +goose Up
+-- Deferrable unique constraints are checked at the end of each statement
+-- instead of after every row, so positions can be shifted with one UPDATE,
+-- and a reorder can defer them to the end of its transaction.
+ALTER TABLE lessons DROP CONSTRAINT lessons_course_id_position_key;
+ALTER TABLE lessons ADD CONSTRAINT unique_course_lesson_position
+    UNIQUE (course_id, "position") DEFERRABLE INITIALLY IMMEDIATE;
+
+ALTER TABLE task_steps DROP CONSTRAINT unique_task_step_position;
+ALTER TABLE task_steps ADD CONSTRAINT unique_task_step_position
+    UNIQUE (task_id, position) DEFERRABLE INITIALLY IMMEDIATE;
+
+-- +goose Down
+ALTER TABLE task_steps DROP CONSTRAINT unique_task_step_position;
+ALTER TABLE task_steps ADD CONSTRAINT unique_task_step_position UNIQUE (task_id, position);
+
+ALTER TABLE lessons DROP CONSTRAINT unique_course_lesson_position;
+ALTER TABLE lessons ADD CONSTRAINT lessons_course_id_position_key UNIQUE (course_id, "position");
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Existing content stays visible. New courses start as drafts, new lessons
+-- as published, so they show up once their course does. A published row with
+-- a publish_at in the future is scheduled: students see it from then on.
+ALTER TABLE courses ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CONSTRAINT courses_status_valid CHECK (status IN ('draft', 'published', 'archived'));
+ALTER TABLE courses ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE courses ADD COLUMN publish_at TIMESTAMP;
+
+ALTER TABLE lessons ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CONSTRAINT lessons_status_valid CHECK (status IN ('draft', 'published', 'archived'));
+ALTER TABLE lessons ADD COLUMN publish_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE lessons DROP COLUMN publish_at;
+ALTER TABLE lessons DROP COLUMN status;
+
+ALTER TABLE courses DROP COLUMN publish_at;
+ALTER TABLE courses DROP COLUMN status;
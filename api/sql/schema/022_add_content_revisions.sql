@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE content_revisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    entity_type TEXT NOT NULL, -- course, lesson, task or task_step
+    entity_id UUID NOT NULL,   -- No foreign key, history outlives the row
+    version INTEGER NOT NULL,  -- Counts up from 1 per row
+    action TEXT NOT NULL,      -- created, updated or rolled_back
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    snapshot JSONB NOT NULL,   -- The row after the change, as the API returns it
+    CONSTRAINT content_revisions_entity_type_valid CHECK (entity_type IN ('course', 'lesson', 'task', 'task_step')),
+    CONSTRAINT unique_revision_version UNIQUE (entity_type, entity_id, version)
+);
+
+-- Existing content starts out with its current state as the first revision
+INSERT INTO content_revisions (id, created_at, entity_type, entity_id, version, action, snapshot)
+SELECT gen_random_uuid(), c.updated_at, 'course', c.id, 1, 'created', to_jsonb(c) FROM courses c;
+INSERT INTO content_revisions (id, created_at, entity_type, entity_id, version, action, snapshot)
+SELECT gen_random_uuid(), l.updated_at, 'lesson', l.id, 1, 'created', to_jsonb(l) FROM lessons l;
+INSERT INTO content_revisions (id, created_at, entity_type, entity_id, version, action, snapshot)
+SELECT gen_random_uuid(), t.updated_at, 'task', t.id, 1, 'created', to_jsonb(t) FROM tasks t;
+INSERT INTO content_revisions (id, created_at, entity_type, entity_id, version, action, snapshot)
+SELECT gen_random_uuid(), s.updated_at, 'task_step', s.id, 1, 'created', to_jsonb(s) FROM task_steps s;
+
+-- +goose Down
+DROP TABLE content_revisions;
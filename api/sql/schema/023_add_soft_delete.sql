@@ -0,0 +1,57 @@
+-- +goose Up
+-- Deleting content only sets deleted_at. A row and everything deleted along
+-- with it get the same deleted_at, which is how a restore finds them again.
+ALTER TABLE courses ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE lessons ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE task_steps ADD COLUMN deleted_at TIMESTAMP;
+
+-- Rows in the trash give up their slug, position and task slot, so they
+-- don't get in the way of new content
+ALTER TABLE courses DROP CONSTRAINT unique_course_slug;
+CREATE UNIQUE INDEX unique_course_slug ON courses (slug) WHERE deleted_at IS NULL;
+ALTER TABLE lessons DROP CONSTRAINT unique_course_lesson_slug;
+CREATE UNIQUE INDEX unique_course_lesson_slug ON lessons (course_id, slug) WHERE deleted_at IS NULL;
+ALTER TABLE tasks DROP CONSTRAINT unique_lesson_task;
+CREATE UNIQUE INDEX unique_lesson_task ON tasks (lesson_id) WHERE deleted_at IS NULL;
+
+-- Partial unique indexes can't be deferred, exclusion constraints can. They
+-- use btree, GiST would need the btree_gist extension for uuid and int
+ALTER TABLE lessons DROP CONSTRAINT unique_course_lesson_position;
+ALTER TABLE lessons ADD CONSTRAINT unique_course_lesson_position
+    EXCLUDE USING btree (course_id WITH =, "position" WITH =) WHERE (deleted_at IS NULL) DEFERRABLE INITIALLY IMMEDIATE;
+ALTER TABLE task_steps DROP CONSTRAINT unique_task_step_position;
+ALTER TABLE task_steps ADD CONSTRAINT unique_task_step_position
+    EXCLUDE USING btree (task_id WITH =, position WITH =) WHERE (deleted_at IS NULL) DEFERRABLE INITIALLY IMMEDIATE;
+
+-- For the trash listing and the purge
+CREATE INDEX courses_deleted_at_idx ON courses (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX lessons_deleted_at_idx ON lessons (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX tasks_deleted_at_idx ON tasks (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX task_steps_deleted_at_idx ON task_steps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+-- The trash is emptied, its rows would break the constraints below
+DELETE FROM task_steps WHERE deleted_at IS NOT NULL;
+DELETE FROM tasks WHERE deleted_at IS NOT NULL;
+DELETE FROM lessons WHERE deleted_at IS NOT NULL;
+DELETE FROM courses WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE task_steps DROP CONSTRAINT unique_task_step_position;
+ALTER TABLE task_steps ADD CONSTRAINT unique_task_step_position
+    UNIQUE (task_id, position) DEFERRABLE INITIALLY IMMEDIATE;
+ALTER TABLE lessons DROP CONSTRAINT unique_course_lesson_position;
+ALTER TABLE lessons ADD CONSTRAINT unique_course_lesson_position
+    UNIQUE (course_id, "position") DEFERRABLE INITIALLY IMMEDIATE;
+
+DROP INDEX unique_lesson_task;
+ALTER TABLE tasks ADD CONSTRAINT unique_lesson_task UNIQUE (lesson_id);
+DROP INDEX unique_course_lesson_slug;
+ALTER TABLE lessons ADD CONSTRAINT unique_course_lesson_slug UNIQUE (course_id, slug);
+DROP INDEX unique_course_slug;
+ALTER TABLE courses ADD CONSTRAINT unique_course_slug UNIQUE (slug);
+
+ALTER TABLE task_steps DROP COLUMN deleted_at;
+ALTER TABLE tasks DROP COLUMN deleted_at;
+ALTER TABLE lessons DROP COLUMN deleted_at;
+ALTER TABLE courses DROP COLUMN deleted_at;
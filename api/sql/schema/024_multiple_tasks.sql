@@ -0,0 +1,36 @@
+-- +goose Up
+-- A lesson holds an ordered list of tasks, or none at all when it is only
+-- meant to be read
+ALTER TABLE tasks ADD COLUMN position INT NOT NULL DEFAULT 1 CHECK (position > 0);
+ALTER TABLE tasks ALTER COLUMN position DROP DEFAULT;
+
+-- Deferrable like the positions of lessons and steps, see 023
+DROP INDEX unique_lesson_task;
+ALTER TABLE tasks ADD CONSTRAINT unique_lesson_task_position
+    EXCLUDE USING btree (lesson_id WITH =, position WITH =) WHERE (deleted_at IS NULL) DEFERRABLE INITIALLY IMMEDIATE;
+
+-- Lessons without tasks are completed by marking them read
+CREATE TABLE lesson_reads (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+
+    CONSTRAINT unique_user_lesson_read UNIQUE (user_id, lesson_id)
+);
+
+-- +goose Down
+DROP TABLE lesson_reads;
+
+-- Only the first task of a lesson stays, the others go to the trash
+UPDATE tasks t
+SET deleted_at = NOW()
+WHERE t.deleted_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM tasks o
+      WHERE o.lesson_id = t.lesson_id AND o.deleted_at IS NULL AND o.position < t.position
+  );
+
+ALTER TABLE tasks DROP CONSTRAINT unique_lesson_task_position;
+CREATE UNIQUE INDEX unique_lesson_task ON tasks (lesson_id) WHERE deleted_at IS NULL;
+ALTER TABLE tasks DROP COLUMN position;
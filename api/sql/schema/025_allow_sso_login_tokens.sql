@@ -0,0 +1,11 @@
+-- +goose Up
+-- SSO callbacks hand the frontend a one-time sso_login code, see ExchangeSSOCode
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'sso_login'));
+
+-- +goose Down
+DELETE FROM user_tokens WHERE purpose = 'sso_login';
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password'));
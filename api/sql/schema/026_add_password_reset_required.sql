@@ -0,0 +1,12 @@
+-- +goose Up
+-- Set by an admin's forced password reset, blocks every login until the user picks a new password
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Forced resets used to clear the password instead. Accounts that can only
+-- log in with SSO have no password either, those with an identity are left alone.
+UPDATE users SET password_reset_required = TRUE
+WHERE password_hash = ''
+  AND NOT EXISTS (SELECT 1 FROM user_identities WHERE user_identities.user_id = users.id);
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN password_reset_required;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Accounts created by SSO have no password, they confirm deleting the
+-- account or turning off 2FA with a fresh SSO login instead
+ALTER TABLE oidc_logins ADD COLUMN reauth BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'sso_login', 'sso_reauth'));
+
+-- +goose Down
+DELETE FROM user_tokens WHERE purpose = 'sso_reauth';
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'sso_login'));
+
+ALTER TABLE oidc_logins DROP COLUMN reauth;
@@ -3,16 +3,31 @@ import { apiClient } from "./client";
 // Define what the backend returns when we log in
 export interface LoginResponse {
   token: string;
+  refresh_token: string;
+  expires_in: number;
   user: {
     id: string;
     username: string;
   };
 }
 
+// Returned by /auth/login instead of tokens when the account has 2FA on
+export interface MFARequiredResponse {
+  mfa_required: true;
+  mfa_token: string;
+}
+
 export interface ApiKeyResponse {
   api_key: string;
 }
 
+export interface DeviceCodeInfo {
+  user_code: string;
+  client_name: string;
+  scopes: string[];
+  expires_at: string;
+}
+
 export interface RegisterResponse {
   id: string;
   username: string;
@@ -23,13 +38,64 @@ export interface RegisterResponse {
 export async function loginUser(
   username: string,
   pass: string,
-): Promise<LoginResponse> {
-  return apiClient<LoginResponse>("/auth/login", {
+): Promise<LoginResponse | MFARequiredResponse> {
+  return apiClient<LoginResponse | MFARequiredResponse>("/auth/login", {
     method: "POST",
     body: JSON.stringify({ username, password: pass }),
   });
 }
 
+// Second login step with a code from the authenticator app
+export async function loginMFA(
+  mfaToken: string,
+  code: string,
+): Promise<LoginResponse> {
+  return apiClient<LoginResponse>("/auth/login/2fa", {
+    method: "POST",
+    body: JSON.stringify({ mfa_token: mfaToken, code }),
+  });
+}
+
+// Single sign-on: the browser goes to the provider and comes back with an
+// sso_code in the URL, which is traded for the usual login answer
+export async function getSSOProviders(): Promise<{ providers: string[] }> {
+  return apiClient<{ providers: string[] }>("/auth/sso");
+}
+
+export async function exchangeSSOCode(
+  code: string,
+): Promise<LoginResponse | MFARequiredResponse> {
+  return apiClient<LoginResponse | MFARequiredResponse>("/auth/sso/token", {
+    method: "POST",
+    body: JSON.stringify({ code }),
+  });
+}
+
+export async function linkSSO(provider: string): Promise<{ url: string }> {
+  return apiClient<{ url: string }>(
+    `/auth/sso/${encodeURIComponent(provider)}/link`,
+    { method: "POST" },
+  );
+}
+
+// Logs in again with a linked provider. Accounts without a password use the
+// sso_reauth code it brings back where others need their password.
+export async function reauthSSO(provider: string): Promise<{ url: string }> {
+  return apiClient<{ url: string }>(
+    `/auth/sso/${encodeURIComponent(provider)}/reauth`,
+    { method: "POST" },
+  );
+}
+
+// Revokes the session on the server. Access tokens of this session stop
+// working immediately.
+export async function logoutUser(refreshToken: string): Promise<void> {
+  await apiClient<void>("/auth/logout", {
+    method: "POST",
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+}
+
 export async function generateApiKey(): Promise<ApiKeyResponse> {
   return apiClient<ApiKeyResponse>("/auth/token", {
     method: "POST",
@@ -46,3 +112,50 @@ export async function registerUser(
     body: JSON.stringify({ username, email, password: pass }),
   });
 }
+
+// Device login: the CLI shows a code, the user approves it here
+export async function getDeviceCode(
+  userCode: string,
+): Promise<DeviceCodeInfo> {
+  return apiClient<DeviceCodeInfo>(
+    `/auth/device/${encodeURIComponent(userCode)}`,
+  );
+}
+
+export async function approveDeviceCode(userCode: string): Promise<void> {
+  await apiClient<void>(
+    `/auth/device/${encodeURIComponent(userCode)}/approve`,
+    { method: "POST" },
+  );
+}
+
+export async function denyDeviceCode(userCode: string): Promise<void> {
+  await apiClient<void>(`/auth/device/${encodeURIComponent(userCode)}/deny`, {
+    method: "POST",
+  });
+}
+
+// Email verification and password reset, the tokens arrive by email
+export async function verifyEmail(token: string): Promise<void> {
+  await apiClient<void>("/auth/verify-email", {
+    method: "POST",
+    body: JSON.stringify({ token }),
+  });
+}
+
+export async function requestPasswordReset(email: string): Promise<void> {
+  await apiClient<void>("/auth/password-reset/request", {
+    method: "POST",
+    body: JSON.stringify({ email }),
+  });
+}
+
+export async function resetPassword(
+  token: string,
+  password: string,
+): Promise<void> {
+  await apiClient<void>("/auth/password-reset", {
+    method: "POST",
+    body: JSON.stringify({ token, password }),
+  });
+}
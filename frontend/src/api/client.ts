@@ -1,9 +1,33 @@
 export const API_BASE = "http://localhost:8080";
 
+// Trades the stored refresh token for a new token pair. Returns false if the
+// session is gone (expired, revoked or never existed).
+async function refreshTokens(): Promise<boolean> {
+  const refreshToken = localStorage.getItem("t_learn_refresh_token");
+  if (!refreshToken) return false;
+
+  const response = await fetch(`${API_BASE}/auth/refresh`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+  if (!response.ok) {
+    localStorage.removeItem("t_learn_token");
+    localStorage.removeItem("t_learn_refresh_token");
+    return false;
+  }
+
+  const data = await response.json();
+  localStorage.setItem("t_learn_token", data.token);
+  localStorage.setItem("t_learn_refresh_token", data.refresh_token);
+  return true;
+}
+
 // A wrapper around the native fetch API
 export async function apiClient<T>(
   endpoint: string,
   options: RequestInit = {},
+  retry = true,
 ): Promise<T> {
   // Get the token from localStorage
   const token = localStorage.getItem("t_learn_token");
@@ -23,6 +47,11 @@ export async function apiClient<T>(
     headers,
   });
 
+  // Access tokens are short lived, refresh once and try again
+  if (response.status === 401 && retry && token && (await refreshTokens())) {
+    return apiClient<T>(endpoint, options, false);
+  }
+
   // Handle Errors
   if (!response.ok) {
     // Try to parse the error message from the backend JSON
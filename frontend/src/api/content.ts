@@ -1,24 +1,50 @@
 import { apiClient } from "./client";
 
+export type CourseStatus = "draft" | "published" | "archived";
+
 export interface Course {
   id: string;
   title: string;
   description: string;
+  status: CourseStatus;
+  publish_at: string | null; // Scheduled publishing
 }
 
 export interface Lesson {
   id: string;
   title: string;
   position: number;
+  tasks: number; // 0 for lessons that are only read
   completed: boolean;
 }
 
+// How a step's output is compared with expected_output.
+// The server does the grading; these are the modes it accepts.
+export type MatchMode =
+  | "exact"
+  | "trimmed"
+  | "regex"
+  | "contains"
+  | "json"
+  | "numeric";
+
 export interface TaskStep {
   position: number;
   command: string;
   expected_output: string;
+  match_mode: MatchMode;
+  tolerance?: number; // Only set for "numeric"
 }
 
+export interface LessonTask {
+  id: string;
+  position: number;
+  description: string;
+  steps: TaskStep[];
+}
+
+// task_id, task_description and steps repeat the first of tasks, for older
+// clients. All of them are empty for reading lessons.
 export interface TaskResponse {
   lesson_id: string;
   lesson_title: string;
@@ -26,6 +52,7 @@ export interface TaskResponse {
   task_id: string;
   task_description: string;
   steps: TaskStep[];
+  tasks: LessonTask[];
 }
 
 export async function getCourses(): Promise<Course[]> {
@@ -40,8 +67,27 @@ export async function getTask(lessonId: string): Promise<TaskResponse> {
   return apiClient<TaskResponse>(`/lessons/${lessonId}/task`);
 }
 
+// Completes a lesson that has no tasks.
+export async function markLessonRead(lessonId: string) {
+  return apiClient(`/lessons/${lessonId}/read`, {
+    method: "POST",
+  });
+}
+
 // --- ADMIN API ---
 
+// Every course, drafts included.
+export async function getAdminCourses(): Promise<Course[]> {
+  return apiClient<Course[]>("/admin/courses");
+}
+
+export async function setCourseStatus(courseId: string, status: CourseStatus) {
+  return apiClient(`/admin/courses/${courseId}/status`, {
+    method: "PUT",
+    body: JSON.stringify({ status }),
+  });
+}
+
 export async function createCourse(title: string, description: string) {
   return apiClient("/admin/courses", {
     method: "POST",
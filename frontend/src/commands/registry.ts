@@ -1,12 +1,33 @@
-import type { CommandDefinition } from "../types";
-import type { Course, Lesson } from "../api/content";
-import { loginUser, generateApiKey, registerUser } from "../api/auth";
+import type { CommandDefinition, CommandResponse } from "../types";
+import type { Course, CourseStatus, Lesson } from "../api/content";
+import type { LoginResponse } from "../api/auth";
+import {
+  loginUser,
+  loginMFA,
+  logoutUser,
+  generateApiKey,
+  registerUser,
+  getDeviceCode,
+  approveDeviceCode,
+  denyDeviceCode,
+  verifyEmail,
+  requestPasswordReset,
+  resetPassword,
+  getSSOProviders,
+  exchangeSSOCode,
+  linkSSO,
+  reauthSSO,
+} from "../api/auth";
+import { API_BASE } from "../api/client";
 import {
   getCourses,
   getLessons,
   getTask,
+  markLessonRead,
   createCourse,
   deleteCourse,
+  getAdminCourses,
+  setCourseStatus,
   createLesson,
   deleteLesson,
 } from "../api/content";
@@ -17,6 +38,7 @@ interface ShellState {
   path: string[]; // e.g. ["Go Mastery"]
   cachedCourses: Course[];
   cachedLessons: Lesson[];
+  pendingMFA: { token: string } | null;
 }
 
 // Initial State
@@ -25,6 +47,7 @@ const state: ShellState = {
   path: [],
   cachedCourses: [],
   cachedLessons: [],
+  pendingMFA: null,
 };
 
 // --- HELPER: Resolve ID by Exact ID or Fuzzy Name ---
@@ -66,12 +89,22 @@ const help: CommandDefinition = {
   clear                         - Clear the terminal
   register <user> <mail> <pass> - Create account
   login <user> <pass>           - Log in
+  otp <code>                    - Finish a login with 2FA
+  sso [provider]                - Log in with single sign-on
+  sso link <provider>           - Link single sign-on to your account
+  sso reauth <provider>         - Confirm it is you, for accounts without a password
   logout                        - Log out
+  verify <code>                 - Confirm your email address
+  forgot <email>                - Email a password reset code
+  reset <code> <new_pass>       - Set a new password
   whoami                        - Show current user
   token                         - Generate CLI API Key
+  device <code>                 - Approve a CLI login
+  device deny <code>            - Reject a CLI login
   courses                       - List available courses
   lessons <course_name>         - Enter a course
   start <lesson_name>           - Start a lesson task
+  read <lesson_name>            - Finish a lesson without tasks
 \`\`\`
 `,
     };
@@ -114,24 +147,155 @@ const login: CommandDefinition = {
     const [username, password] = args;
     try {
       const data = await loginUser(username, password);
-      localStorage.setItem("t_learn_token", data.token);
+      if ("mfa_required" in data) {
+        state.pendingMFA = { token: data.mfa_token };
+        return {
+          type: "info",
+          output:
+            "Two-factor authentication is on for this account.\n" +
+            "Run: otp <code> (or a recovery code)",
+        };
+      }
+
+      return finishLogin(username, data);
+    } catch (err: any) {
+      return { type: "error", output: `Login failed: ${err.message}` };
+    }
+  },
+};
 
-      // Update State
-      state.user = username;
-      localStorage.setItem("t_learn_user", username);
+function finishLogin(username: string, data: LoginResponse): CommandResponse {
+  localStorage.setItem("t_learn_token", data.token);
+  localStorage.setItem("t_learn_refresh_token", data.refresh_token);
 
-      return { type: "success", output: `Logged in as ${username}.` };
+  // Update State
+  state.user = username;
+  state.pendingMFA = null;
+  localStorage.setItem("t_learn_user", username);
+
+  return { type: "success", output: `Logged in as ${username}.` };
+}
+
+const otp: CommandDefinition = {
+  description: "Finish a login with a 2FA code",
+  execute: async (args) => {
+    if (args.length < 1) return { type: "error", output: "Usage: otp <code>" };
+    if (!state.pendingMFA)
+      return { type: "error", output: "Run 'login <user> <pass>' first." };
+
+    const { token } = state.pendingMFA;
+    try {
+      const data = await loginMFA(token, args[0]);
+      return finishLogin(data.user.username, data);
     } catch (err: any) {
       return { type: "error", output: `Login failed: ${err.message}` };
     }
   },
 };
 
+const sso: CommandDefinition = {
+  description: "Log in with single sign-on",
+  execute: async (args) => {
+    if (args[0] === "link") {
+      if (args.length < 2)
+        return { type: "error", output: "Usage: sso link <provider>" };
+      try {
+        const { url } = await linkSSO(args[1]);
+        window.location.href = url;
+        return { type: "info", output: `Redirecting to ${args[1]}...` };
+      } catch (err: any) {
+        return { type: "error", output: `Linking failed: ${err.message}` };
+      }
+    }
+
+    if (args[0] === "reauth") {
+      if (args.length < 2)
+        return { type: "error", output: "Usage: sso reauth <provider>" };
+      try {
+        const { url } = await reauthSSO(args[1]);
+        window.location.href = url;
+        return { type: "info", output: `Redirecting to ${args[1]}...` };
+      } catch (err: any) {
+        return { type: "error", output: `Sign-on failed: ${err.message}` };
+      }
+    }
+
+    if (args.length < 1) {
+      try {
+        const { providers } = await getSSOProviders();
+        if (providers.length === 0)
+          return { type: "info", output: "Single sign-on is not set up." };
+        return {
+          type: "info",
+          output: `Providers: ${providers.join(", ")}\nRun: sso <provider>`,
+        };
+      } catch (err: any) {
+        return { type: "error", output: `Failed: ${err.message}` };
+      }
+    }
+
+    window.location.href = `${API_BASE}/auth/sso/${encodeURIComponent(args[0])}/login`;
+    return { type: "info", output: `Redirecting to ${args[0]}...` };
+  },
+};
+
+// Finishes a single sign-on after the provider sent the browser back here.
+// Returns null when the page was not opened by a sign-on redirect.
+export async function handleSSORedirect(): Promise<CommandResponse | null> {
+  const params = new URLSearchParams(window.location.search);
+  const code = params.get("sso_code");
+  const error = params.get("sso_error");
+  const linked = params.get("sso_linked");
+  const reauth = params.get("sso_reauth");
+  if (!code && !error && !linked && !reauth) return null;
+
+  // Don't leave the one-time code in the address bar or history
+  window.history.replaceState(null, "", window.location.pathname);
+
+  if (error) return { type: "error", output: `Sign-on failed: ${error}` };
+  if (linked)
+    return { type: "success", output: `Linked your ${linked} account.` };
+  if (reauth)
+    return {
+      type: "success",
+      output:
+        "Confirmed it is you. For the next 5 minutes, send this\n" +
+        `reauth_code where a password is asked for: ${reauth}`,
+    };
+
+  try {
+    const data = await exchangeSSOCode(code!);
+    if ("mfa_required" in data) {
+      state.pendingMFA = { token: data.mfa_token };
+      return {
+        type: "info",
+        output:
+          "Two-factor authentication is on for this account.\n" +
+          "Run: otp <code> (or a recovery code)",
+      };
+    }
+    return finishLogin(data.user.username, data);
+  } catch (err: any) {
+    return { type: "error", output: `Sign-on failed: ${err.message}` };
+  }
+}
+
 const logout: CommandDefinition = {
   description: "Log out of the session",
   execute: async () => {
+    // Revoke the session on the server, but log out locally regardless
+    const refreshToken = localStorage.getItem("t_learn_refresh_token");
+    if (refreshToken) {
+      try {
+        await logoutUser(refreshToken);
+      } catch {
+        // Already expired or revoked
+      }
+    }
+
     // Clear the storage
     localStorage.removeItem("t_learn_token");
+    localStorage.removeItem("t_learn_refresh_token");
     localStorage.removeItem("t_learn_user");
 
     // Reset the internal state
@@ -142,6 +306,57 @@ const logout: CommandDefinition = {
   },
 };
 
+const verify: CommandDefinition = {
+  description: "Confirm your email address",
+  execute: async (args) => {
+    if (args.length < 1)
+      return { type: "error", output: "Usage: verify <code>" };
+    try {
+      await verifyEmail(args[0]);
+      return { type: "success", output: "Email address verified." };
+    } catch (err: any) {
+      return { type: "error", output: `Verification failed: ${err.message}` };
+    }
+  },
+};
+
+const forgot: CommandDefinition = {
+  description: "Email a password reset code",
+  execute: async (args) => {
+    if (args.length < 1)
+      return { type: "error", output: "Usage: forgot <email>" };
+    try {
+      await requestPasswordReset(args[0]);
+      return {
+        type: "success",
+        output:
+          "If an account uses this address, a reset code is on its way.\n" +
+          "Then run: reset <code> <new_password>",
+      };
+    } catch (err: any) {
+      return { type: "error", output: `Failed: ${err.message}` };
+    }
+  },
+};
+
+const reset: CommandDefinition = {
+  description: "Set a new password",
+  execute: async (args) => {
+    if (args.length < 2)
+      return { type: "error", output: "Usage: reset <code> <new_password>" };
+    const [code, password] = args;
+    try {
+      await resetPassword(code, password);
+      return {
+        type: "success",
+        output: "Password changed. You can now log in.",
+      };
+    } catch (err: any) {
+      return { type: "error", output: `Reset failed: ${err.message}` };
+    }
+  },
+};
+
 const whoami: CommandDefinition = {
   description: "Show current user",
   execute: async () => {
@@ -171,6 +386,42 @@ const token: CommandDefinition = {
   },
 };
 
+const device: CommandDefinition = {
+  description: "Approve or reject a CLI login",
+  execute: async (args) => {
+    const deny = args[0] === "deny";
+    const code = deny ? args[1] : args[0];
+    if (!code)
+      return {
+        type: "error",
+        output: "Usage: device <code> or device deny <code>",
+      };
+
+    try {
+      const info = await getDeviceCode(code);
+      if (deny) {
+        await denyDeviceCode(code);
+        return {
+          type: "success",
+          output: `Rejected login for **${info.client_name}**.`,
+        };
+      }
+
+      await approveDeviceCode(code);
+      return {
+        type: "success",
+        output:
+          `### 🔑 CLI Login Approved\n` +
+          `**${info.client_name}** can now use your account with the scopes: ` +
+          `${info.scopes.join(", ")}.\n\n` +
+          `You can return to your terminal.`,
+      };
+    } catch (err: any) {
+      return { type: "error", output: `Failed: ${err.message}` };
+    }
+  },
+};
+
 const courses: CommandDefinition = {
   description: "List available courses",
   execute: async () => {
@@ -224,7 +475,8 @@ const lessons: CommandDefinition = {
       const list = lessons
         .map((l) => {
           const mark = l.completed ? "x" : " "; // x for done, space for todo
-          return `- [${mark}] ${l.title}`;
+          const kind = l.tasks === 0 ? " (reading)" : "";
+          return `- [${mark}] ${l.title}${kind}`;
         })
         .join("\n");
 
@@ -265,20 +517,33 @@ const start: CommandDefinition = {
       // The content (includes the CLI Helper we added in the seeder)
       output += `${data.lesson_content}\n\n`;
 
-      // --- Task Section ---
-      output += `## 🎯 Your Task\n`;
-      output += `${data.task_description}\n\n`;
-
-      if (data.steps && data.steps.length > 0) {
-        output += `**Steps to execute:**\n`;
-        data.steps.forEach((step: any) => {
-          // Render commands as inline code blocks
-          output += `${step.position}. \`${step.command}\`\n`;
-        });
+      // Reading lessons have nothing to verify
+      if (data.tasks.length === 0) {
+        output += `---\n`;
+        output += `Done reading? Run \`read ${data.lesson_title}\` to complete this lesson.`;
+        return { type: "info", output };
       }
 
+      // --- Task Section ---
+      data.tasks.forEach((task, i) => {
+        output +=
+          data.tasks.length > 1
+            ? `## 🎯 Task ${i + 1} of ${data.tasks.length}\n`
+            : `## 🎯 Your Task\n`;
+        output += `${task.description}\n\n`;
+
+        if (task.steps.length > 0) {
+          output += `**Steps to execute:**\n`;
+          task.steps.forEach((step) => {
+            // Render commands as inline code blocks
+            output += `${step.position}. \`${step.command}\`\n`;
+          });
+        }
+        output += `\n`;
+      });
+
       // --- Verification Section ---
-      output += `\n---\n`;
+      output += `---\n`;
       output += `### ✅ Verification\n`;
       output += `Run this command to check your work:\n`;
 
@@ -292,6 +557,34 @@ const start: CommandDefinition = {
   },
 };
 
+const read: CommandDefinition = {
+  description: "Mark a lesson without tasks as read",
+  execute: async (args) => {
+    if (args.length < 1)
+      return { type: "error", output: "Usage: read <lesson_name>" };
+
+    const query = args.join(" ");
+
+    const lessonId = resolveId(query, state.cachedLessons);
+    if (!lessonId) {
+      return {
+        type: "error",
+        output: `Lesson '${query}' not found.\n(Did you run 'lessons <course>' first?)`,
+      };
+    }
+
+    try {
+      await markLessonRead(lessonId);
+      state.cachedLessons = state.cachedLessons.map((l) =>
+        l.id === lessonId ? { ...l, completed: true } : l,
+      );
+      return { type: "success", output: `Lesson '${query}' completed.` };
+    } catch (err: any) {
+      return { type: "error", output: `Failed: ${err.message}` };
+    }
+  },
+};
+
 // --- ADMIN COMMANDS (SMART VERSIONS) ---
 
 const mkcourse: CommandDefinition = {
@@ -307,8 +600,11 @@ const mkcourse: CommandDefinition = {
     const [title, desc] = args;
     try {
       await createCourse(title, desc);
-      state.cachedCourses = await getCourses(); // Refresh cache immediately
-      return { type: "success", output: `Course "${title}" created.` };
+      state.cachedCourses = await getAdminCourses(); // Refresh cache immediately
+      return {
+        type: "success",
+        output: `Course "${title}" created as a draft. Run 'publish ${title}' to show it to students.`,
+      };
     } catch (err: any) {
       return { type: "error", output: `Failed: ${err.message}` };
     }
@@ -326,7 +622,7 @@ const rmcourse: CommandDefinition = {
     // Ensure we have the list to look up names
     if (state.cachedCourses.length === 0) {
       try {
-        state.cachedCourses = await getCourses();
+        state.cachedCourses = await getAdminCourses();
       } catch (e) {}
     }
 
@@ -339,8 +635,8 @@ const rmcourse: CommandDefinition = {
 
     try {
       await deleteCourse(courseId);
-      state.cachedCourses = await getCourses(); // Refresh cache
-      return { type: "success", output: `Course '${query}' deleted.` };
+      state.cachedCourses = await getAdminCourses(); // Refresh cache
+      return { type: "success", output: `Course '${query}' moved to the trash.` };
     } catch (err: any) {
       return { type: "error", output: `Failed: ${err.message}` };
     }
@@ -368,7 +664,7 @@ const mklesson: CommandDefinition = {
     // We need to ensure cache exists, just like rmcourse
     if (state.cachedCourses.length === 0) {
       try {
-        state.cachedCourses = await getCourses();
+        state.cachedCourses = await getAdminCourses();
       } catch (e) {}
     }
     const courseId = resolveId(courseQuery, state.cachedCourses);
@@ -388,6 +684,44 @@ const mklesson: CommandDefinition = {
   },
 };
 
+const publish: CommandDefinition = {
+  description: "Publish, unpublish or archive a course (Admin)",
+  execute: async (args) => {
+    // Usage: publish "Go Mastery", publish --draft "Go Mastery"
+    const flags: Record<string, CourseStatus> = {
+      "--draft": "draft",
+      "--archive": "archived",
+    };
+    let status: CourseStatus = "published";
+    if (args[0] in flags) {
+      status = flags[args[0]];
+      args = args.slice(1);
+    }
+    if (args.length < 1)
+      return {
+        type: "error",
+        output: "Usage: publish [--draft|--archive] <course_name_or_id>",
+      };
+
+    const query = args.join(" ");
+    try {
+      state.cachedCourses = await getAdminCourses();
+    } catch (e) {}
+    const courseId = resolveId(query, state.cachedCourses);
+
+    if (!courseId)
+      return { type: "error", output: `Course '${query}' not found.` };
+
+    try {
+      await setCourseStatus(courseId, status);
+      state.cachedCourses = await getAdminCourses();
+      return { type: "success", output: `Course '${query}' is now ${status}.` };
+    } catch (err: any) {
+      return { type: "error", output: `Failed: ${err.message}` };
+    }
+  },
+};
+
 const rmlesson: CommandDefinition = {
   description: "Delete a lesson by Name or ID (Admin)",
   execute: async (args) => {
@@ -411,7 +745,7 @@ const rmlesson: CommandDefinition = {
       state.cachedLessons = state.cachedLessons.filter(
         (l) => l.id !== lessonId,
       );
-      return { type: "success", output: `Lesson '${query}' deleted.` };
+      return { type: "success", output: `Lesson '${query}' moved to the trash.` };
     } catch (err: any) {
       return { type: "error", output: `Failed: ${err.message}` };
     }
@@ -424,14 +758,22 @@ export const commands: Record<string, CommandDefinition> = {
   clear,
   register,
   login,
+  otp,
+  sso,
   logout,
+  verify,
+  forgot,
+  reset,
   whoami,
   token,
+  device,
   courses,
   lessons,
   start,
+  read,
   mkcourse,
   rmcourse,
   mklesson,
+  publish,
   rmlesson,
 };
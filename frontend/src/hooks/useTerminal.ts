@@ -1,6 +1,10 @@
-import { useState, useCallback } from "react";
+import { useState, useCallback, useEffect } from "react";
 import type { HistoryLine, LineType } from "../types";
-import { commands, getPrompt } from "../commands/registry";
+import {
+  commands,
+  getPrompt,
+  handleSSORedirect,
+} from "../commands/registry";
 
 export const useTerminal = () => {
   const [history, setHistory] = useState<HistoryLine[]>([
@@ -23,6 +27,13 @@ export const useTerminal = () => {
     ]);
   };
 
+  // Pick up the result of a single sign-on redirect
+  useEffect(() => {
+    handleSSORedirect().then((response) => {
+      if (response) pushToHistory(response.output, response.type);
+    });
+  }, []);
+
   const execute = useCallback(async (commandString: string) => {
     if (!commandString.trim()) return;
 